@@ -0,0 +1,223 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"time"
+)
+
+/**
+ *  gpbackup备份时效抓取器
+ *  读取master主机上gpbackup生成的gpbackup_history.yaml，或者用户维护的备份历史表，按数据库与备份类型上报最近一次备份的信息
+ *  新版gpbackup的gpbackup_history.db为SQLite格式，exporter不包含SQLite驱动，需要通过备份历史表上报
+ *  备份历史表需要包含database_name, backup_type, status, start_time, end_time, size_bytes列，status为Success表示成功、Failure表示失败
+ *  只统计已结束（Success/Failure）的备份，正在进行中的备份不影响最近一次备份的状态
+ */
+
+const (
+	backupHistoryTableSql = `SELECT database_name, backup_type, status, start_time, end_time, coalesce(size_bytes, -1) from %s;`
+	//gpbackup时间戳格式，为master主机的本地时间
+	backupTimestampLayout = "20060102150405"
+	backupStatusSuccess   = "Success"
+	backupStatusFailure   = "Failure"
+)
+
+var (
+	backupHistoryFile = kingpin.Flag("collect.backup.history-file",
+		"path of gpbackup_history.yaml on the master host, empty to disable").Default("").String()
+	backupHistoryTable = kingpin.Flag("collect.backup.history-table",
+		"schema qualified table in the postgres database holding backup history, empty to disable").Default("").String()
+)
+
+var (
+	backupLastSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "backup_last_success_timestamp"),
+		"End time of the last successful backup of each database and backup type",
+		[]string{"dbname", "type"}, nil,
+	)
+
+	backupLastDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "backup_last_success_duration_seconds"),
+		"Duration of the last successful backup of each database and backup type",
+		[]string{"dbname", "type"}, nil,
+	)
+
+	backupLastSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "backup_last_success_size_bytes"),
+		"Size of the last successful backup of each database and backup type, only available from the backup history table",
+		[]string{"dbname", "type"}, nil,
+	)
+
+	backupLastStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "backup_last_status"),
+		"Whether the most recent backup of each database and backup type succeeded",
+		[]string{"dbname", "type"}, nil,
+	)
+)
+
+type backupKey struct {
+	dbname, backupType string
+}
+
+type backupRecord struct {
+	backupKey
+	status     string
+	start, end time.Time
+	size       float64
+}
+
+func NewBackupScraper() Scraper {
+	return backupScraper{}
+}
+
+type backupScraper struct{}
+
+func (backupScraper) Name() string {
+	return "backup_scraper"
+}
+
+func (backupScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	errs := make([]error, 0)
+	records := make([]backupRecord, 0)
+	if *backupHistoryFile != "" {
+		fileRecords, err := readBackupHistoryFile(*backupHistoryFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		records = append(records, fileRecords...)
+	}
+	if *backupHistoryTable != "" {
+		tableRecords, err := readBackupHistoryTable(db, *backupHistoryTable)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		records = append(records, tableRecords...)
+	}
+
+	lastBackup := make(map[backupKey]backupRecord)
+	lastSuccess := make(map[backupKey]backupRecord)
+	for _, r := range records {
+		if r.status != backupStatusSuccess && r.status != backupStatusFailure {
+			continue
+		}
+		if last, ok := lastBackup[r.backupKey]; !ok || r.start.After(last.start) {
+			lastBackup[r.backupKey] = r
+		}
+		if r.status != backupStatusSuccess {
+			continue
+		}
+		if last, ok := lastSuccess[r.backupKey]; !ok || r.start.After(last.start) {
+			lastSuccess[r.backupKey] = r
+		}
+	}
+
+	for key, r := range lastBackup {
+		var status float64
+		if r.status == backupStatusSuccess {
+			status = 1
+		}
+		ch <- prometheus.MustNewConstMetric(backupLastStatusDesc, prometheus.GaugeValue, status, key.dbname, key.backupType)
+	}
+	for key, r := range lastSuccess {
+		ch <- prometheus.MustNewConstMetric(backupLastSuccessDesc, prometheus.GaugeValue, float64(r.end.Unix()), key.dbname, key.backupType)
+		ch <- prometheus.MustNewConstMetric(backupLastDurationDesc, prometheus.GaugeValue, r.end.Sub(r.start).Seconds(), key.dbname, key.backupType)
+		if r.size >= 0 {
+			ch <- prometheus.MustNewConstMetric(backupLastSizeDesc, prometheus.GaugeValue, r.size, key.dbname, key.backupType)
+		}
+	}
+	return combineErr(errs...)
+}
+
+func readBackupHistoryTable(db *sql.DB, table string) ([]backupRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	query := fmt.Sprintf(backupHistoryTableSql, table)
+	logger.Infof("Query Database: %s", query)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := make([]backupRecord, 0)
+	for rows.Next() {
+		var r backupRecord
+		if err = rows.Scan(&r.dbname, &r.backupType, &r.status, &r.start, &r.end, &r.size); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// gpbackup_history.yaml中使用到的字段
+type backupHistory struct {
+	BackupConfigs []backupConfig `yaml:"backupconfigs"`
+}
+
+type backupConfig struct {
+	DatabaseName string `yaml:"databasename"`
+	DataOnly     bool   `yaml:"dataonly"`
+	MetadataOnly bool   `yaml:"metadataonly"`
+	Incremental  bool   `yaml:"incremental"`
+	Timestamp    string `yaml:"timestamp"`
+	EndTime      string `yaml:"endtime"`
+	Status       string `yaml:"status"`
+}
+
+func readBackupHistoryFile(path string) ([]backupRecord, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBackupHistory(data)
+}
+
+/**
+* 函数：parseBackupHistory
+* 功能：解析gpbackup_history.yaml的内容，时间戳按master主机的本地时间解析
+ */
+func parseBackupHistory(data []byte) ([]backupRecord, error) {
+	var history backupHistory
+	if err := yaml.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	records := make([]backupRecord, 0, len(history.BackupConfigs))
+	for _, config := range history.BackupConfigs {
+		start, err := time.ParseInLocation(backupTimestampLayout, config.Timestamp, time.Local)
+		if err != nil {
+			logger.Warnf("invalid backup timestamp %q", config.Timestamp)
+			continue
+		}
+		end, err := time.ParseInLocation(backupTimestampLayout, config.EndTime, time.Local)
+		if err != nil {
+			end = start
+		}
+		records = append(records, backupRecord{
+			backupKey: backupKey{dbname: config.DatabaseName, backupType: config.backupType()},
+			status:    config.Status,
+			start:     start,
+			end:       end,
+			size:      -1,
+		})
+	}
+	return records, nil
+}
+
+func (c backupConfig) backupType() string {
+	switch {
+	case c.MetadataOnly:
+		return "metadata-only"
+	case c.DataOnly:
+		return "data-only"
+	case c.Incremental:
+		return "incremental"
+	default:
+		return "full"
+	}
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+// gpbackup 1.20生成的gpbackup_history.yaml片段
+const backupHistorySample = `backupconfigs:
+- backupdir: ""
+  backupversion: 1.20.1
+  compressed: true
+  compressiontype: gzip
+  databasename: sales
+  databaseversion: 6.14.0 build commit:a5f8bd5fb6a0ae1bfb1d0ba8ff7e5cc2c1ea3e10
+  dataonly: false
+  datedeleted: ""
+  excluderelations: []
+  excludeschemafiltered: false
+  excludeschemas: []
+  excludetablefiltered: false
+  includerelations:
+  - public.orders
+  - public.customers
+  includeschemafiltered: false
+  includeschemas: []
+  includetablefiltered: true
+  incremental: true
+  leafpartitiondata: false
+  metadataonly: false
+  plugin: ""
+  pluginversion: ""
+  restoreplan:
+  - timestamp: "20201018010000"
+    tablefqns:
+    - public.orders
+  singledatafile: false
+  timestamp: "20201019010000"
+  endtime: "20201019013000"
+  status: Success
+  withoutglobals: false
+  withstatistics: false
+- backupdir: /data/backup
+  backupversion: 1.20.1
+  compressed: true
+  compressiontype: gzip
+  databasename: sales
+  dataonly: false
+  incremental: false
+  metadataonly: true
+  timestamp: "20201018010000"
+  endtime: ""
+  status: Failure
+- databasename: hr
+  timestamp: "20201019020000"
+  endtime: ""
+  status: In Progress
+`
+
+func TestParseBackupHistory(t *testing.T) {
+	records, err := parseBackupHistory([]byte(backupHistorySample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dbname, backupType, status string
+		start, end                 string
+	}{
+		{"sales", "incremental", "Success", "20201019010000", "20201019013000"},
+		{"sales", "metadata-only", "Failure", "20201018010000", "20201018010000"},
+		{"hr", "full", "In Progress", "20201019020000", "20201019020000"},
+	}
+	if len(records) != len(tests) {
+		t.Fatalf("got %d records, want %d", len(records), len(tests))
+	}
+	for i, tt := range tests {
+		r := records[i]
+		start, _ := time.ParseInLocation(backupTimestampLayout, tt.start, time.Local)
+		end, _ := time.ParseInLocation(backupTimestampLayout, tt.end, time.Local)
+		if r.dbname != tt.dbname || r.backupType != tt.backupType || r.status != tt.status ||
+			!r.start.Equal(start) || !r.end.Equal(end) || r.size != -1 {
+			t.Errorf("record %d = %+v, want %+v", i, r, tt)
+		}
+	}
+}
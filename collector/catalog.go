@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"time"
+)
+
+/**
+ *  系统表膨胀与一致性抓取器
+ *  按数据库读取master与各segment上pg_attribute/pg_class/pg_type的大小，segment之间差异超过阈值时标记为倾斜
+ *  作为gpcheckcat之外的轻量级早期指标，不做完整的系统表一致性检查
+ */
+
+const (
+	catalogSizeSql = `SELECT -1, relname, pg_relation_size(oid) from pg_class
+		where relnamespace=11 and relname in ('pg_attribute','pg_class','pg_type')
+		UNION ALL
+		SELECT gp_segment_id, relname, pg_relation_size(oid) from gp_dist_random('pg_class')
+		where relnamespace=11 and relname in ('pg_attribute','pg_class','pg_type');`
+	catalogObjectsSql = `SELECT (SELECT count(*) from pg_class), (SELECT count(*) from pg_attribute), (SELECT count(*) from pg_type);`
+)
+
+var (
+	catalogSkewThreshold = kingpin.Flag("collect.catalog.skew-threshold",
+		"ratio of (max-min)/max of a catalog table size between segments above which the table is flagged as skewed").Default("0.2").Float64()
+)
+
+var (
+	catalogTableSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "catalog_table_bytes"),
+		"Size of pg_attribute/pg_class/pg_type of each database on master (content -1) and each segment",
+		[]string{"dbname", "table", "content"}, nil,
+	)
+
+	catalogSkewRatioDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "catalog_table_skew_ratio"),
+		"Ratio of (max-min)/max of the catalog table size between segments",
+		[]string{"dbname", "table"}, nil,
+	)
+
+	catalogSkewedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "catalog_table_skewed"),
+		"Whether the catalog table size skew between segments is above collect.catalog.skew-threshold",
+		[]string{"dbname", "table"}, nil,
+	)
+
+	catalogObjectsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "catalog_objects"),
+		"Number of rows of pg_class/pg_attribute/pg_type of each database on master",
+		[]string{"dbname", "table"}, nil,
+	)
+)
+
+func NewCatalogScraper() Scraper {
+	return catalogScraper{}
+}
+
+type catalogScraper struct{}
+
+func (catalogScraper) Name() string {
+	return "catalog_scraper"
+}
+
+func (catalogScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	names, err := listDatabases(db)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, dbname := range names {
+		if err := scrapeDatabaseCatalog(dbname, ch); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeDatabaseCatalog(dbname string, ch chan<- prometheus.Metric) error {
+	conn, err := getDatabaseConn(dbname)
+	if err != nil {
+		return err
+	}
+	errS := scrapeCatalogSize(conn, dbname, ch)
+	errO := scrapeCatalogObjects(conn, dbname, ch)
+	return combineErr(errS, errO)
+}
+
+func scrapeCatalogSize(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", catalogSizeSql)
+	rows, err := conn.QueryContext(ctx, catalogSizeSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	minSize := make(map[string]float64)
+	maxSize := make(map[string]float64)
+	for rows.Next() {
+		var content, table string
+		var size float64
+		err = rows.Scan(&content, &table, &size)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(catalogTableSizeDesc, prometheus.GaugeValue, size, dbname, table, content)
+		if content == "-1" {
+			continue
+		}
+		if smallest, ok := minSize[table]; !ok || size < smallest {
+			minSize[table] = size
+		}
+		if size > maxSize[table] {
+			maxSize[table] = size
+		}
+	}
+
+	for table, smallest := range minSize {
+		var ratio, skewed float64
+		if largest := maxSize[table]; largest > 0 {
+			ratio = (largest - smallest) / largest
+		}
+		if ratio > *catalogSkewThreshold {
+			skewed = 1
+		}
+		ch <- prometheus.MustNewConstMetric(catalogSkewRatioDesc, prometheus.GaugeValue, ratio, dbname, table)
+		ch <- prometheus.MustNewConstMetric(catalogSkewedDesc, prometheus.GaugeValue, skewed, dbname, table)
+	}
+	return combineErr(errs...)
+}
+
+func scrapeCatalogObjects(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", catalogObjectsSql)
+	var relations, attributes, types float64
+	err := conn.QueryRowContext(ctx, catalogObjectsSql).Scan(&relations, &attributes, &types)
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(catalogObjectsDesc, prometheus.GaugeValue, relations, dbname, "pg_class")
+	ch <- prometheus.MustNewConstMetric(catalogObjectsDesc, prometheus.GaugeValue, attributes, dbname, "pg_attribute")
+	ch <- prometheus.MustNewConstMetric(catalogObjectsDesc, prometheus.GaugeValue, types, dbname, "pg_type")
+	return nil
+}
@@ -7,11 +7,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	logger "github.com/prometheus/common/log"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 )
 
-const checkSql = `select 'OK'`
+const (
+	checkSql       = `select 'OK'`
+	fullVersionSql = `select version()`
+)
+
+// 从version()中识别Greenplum大版本，如 PostgreSQL 9.4.24 (Greenplum Database 6.14.0 build commit:xxx Open Source)
+var gpVersionRegex = regexp.MustCompile(`Greenplum Database (\d+)\.`)
 
 // 定义采集器数据类型结构体
 type GreenPlumCollector struct {
@@ -19,6 +27,12 @@ type GreenPlumCollector struct {
 	db       *sql.DB
 	metrics  *ExporterMetrics
 	scrapers []Scraper
+
+	// 最近一次连接检查成功的时间与识别出的集群版本，供/readyz与首页使用，不随抓取加锁
+	statusMu    sync.RWMutex
+	lastCheckOK time.Time
+	flavor      string
+	version     string
 }
 
 /**
@@ -78,6 +92,9 @@ func (c *GreenPlumCollector) scrape(ch chan<- prometheus.Metric) {
 	}
 	defer c.db.Close()
 	logger.Info("check connections ok!")
+	c.statusMu.Lock()
+	c.lastCheckOK = time.Now()
+	c.statusMu.Unlock()
 	c.metrics.greenplumUp.Set(1)
 	// 遍历执行MAP中的所有抓取器
 	for _, scraper := range c.scrapers {
@@ -132,9 +149,99 @@ func (c *GreenPlumCollector) getGreenPlumConnection() error {
 	db.SetMaxIdleConns(1)
 	db.SetMaxOpenConns(1)
 	c.db = db
+	// 集群版本不会随抓取变化，识别成功后缓存，避免每次重连都多查一次version()
+	if flavor, _ := c.Flavor(); flavor == "" {
+		c.detectFlavor(db)
+	}
 	return nil
 }
 
+/**
+* 函数：StartConnectionCheck
+* 功能：后台按固定间隔独立检查数据库连接并更新最近一次成功时间，使/readyz不依赖/metrics被抓取
+ */
+func (c *GreenPlumCollector) StartConnectionCheck(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			c.checkConnectionOnce()
+			<-ticker.C
+		}
+	}()
+}
+
+/**
+* 函数：checkConnectionOnce
+* 功能：使用单独的短连接执行一次连接检查，不占用抓取的连接与锁，检查完立即关闭
+ */
+func (c *GreenPlumCollector) checkConnectionOnce() {
+	db, err := sql.Open("postgres", os.Getenv("GPDB_DATA_SOURCE_URL"))
+	if err != nil {
+		logger.Warnf("background connection check failed, error:%v", err)
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err = checkGreenPlumConnections(db); err != nil {
+		logger.Warnf("background connection check failed, error:%v", err)
+		return
+	}
+	c.statusMu.Lock()
+	c.lastCheckOK = time.Now()
+	c.statusMu.Unlock()
+	if flavor, _ := c.Flavor(); flavor == "" {
+		c.detectFlavor(db)
+	}
+}
+
+/**
+* 函数：detectFlavor
+* 功能：通过version()识别集群版本，gposs5/gposs6为开源版，gpdb5/gpdb6为Pivotal版，识别失败只记录日志
+ */
+func (c *GreenPlumCollector) detectFlavor(db *sql.DB) {
+	var version string
+	if err := db.QueryRow(fullVersionSql).Scan(&version); err != nil {
+		logger.Warnf("detect greenplum version failed, error:%v", err)
+		return
+	}
+	flavor := "unknown"
+	if match := gpVersionRegex.FindStringSubmatch(version); match != nil {
+		if strings.Contains(version, "Open Source") {
+			flavor = "gposs" + match[1]
+		} else {
+			flavor = "gpdb" + match[1]
+		}
+	}
+	c.statusMu.Lock()
+	c.flavor = flavor
+	c.version = version
+	c.statusMu.Unlock()
+}
+
+/**
+* 函数：LastConnectionCheck
+* 功能：返回最近一次连接检查成功的时间，从未成功时为零值
+ */
+func (c *GreenPlumCollector) LastConnectionCheck() time.Time {
+	c.statusMu.RLock()
+	defer c.statusMu.RUnlock()
+	return c.lastCheckOK
+}
+
+/**
+* 函数：Flavor
+* 功能：返回识别出的集群版本与version()原文，尚未连接过数据库时为空
+ */
+func (c *GreenPlumCollector) Flavor() (flavor, version string) {
+	c.statusMu.RLock()
+	defer c.statusMu.RUnlock()
+	return c.flavor, c.version
+}
+
 /**
 * 函数：checkGreenPlumConnections
 * 功能：使用检测SQL检查Greenplum的连接
@@ -3,8 +3,6 @@ package collector
 import (
 	"container/list"
 	"context"
-	"os"
-	"strings"
 	"database/sql"
 	"github.com/prometheus/client_golang/prometheus"
 	logger "github.com/prometheus/common/log"
@@ -30,6 +28,13 @@ var (
 		nil,                                                                       //定义的Labels
 	)
 
+	databaseGrowthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "database_growth_mb_per_day"),
+		"Growth rate in MB per day of each database by linear regression over collect.history.window",
+		[]string{"dbname"},
+		nil,
+	)
+
 	tablesCountDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "database_table_total_count"),
 		"Total table count of each database name in the file system",
@@ -74,6 +79,7 @@ func (databaseSizeScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error
 	defer rows.Close()
 	errs := make([]error, 0)
 	names := list.New()
+	now := time.Now()
 	for rows.Next() {
 		var dbname string
 		var mbSize float64
@@ -83,6 +89,9 @@ func (databaseSizeScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error
 			continue
 		}
 		ch <- prometheus.MustNewConstMetric(databaseSizeDesc, prometheus.GaugeValue, mbSize, dbname)
+		if rate, ok := sizeHistories.record("database/"+dbname, now, mbSize); ok {
+			ch <- prometheus.MustNewConstMetric(databaseGrowthDesc, prometheus.GaugeValue, rate*86400, dbname)
+		}
 		names.PushBack(dbname)
 	}
 
@@ -104,22 +113,21 @@ func (databaseSizeScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error
 	if errN != nil {
 		errs = append(errs, errN)
 	}
+	errH := sizeHistories.flush()
+	if errH != nil {
+		errs = append(errs, errH)
+	}
 
 	return combineErr(errs...)
 }
 
 func queryTablesCount(dbname string) (count float64, err error) {
-	dataSourceName := os.Getenv("GPDB_DATA_SOURCE_URL")
-	newDataSourceName := strings.Replace(dataSourceName, "/postgres", "/"+dbname, 1)
-	logger.Infof("Connection string is : %s", newDataSourceName)
-	conn, err := sql.Open("postgres", newDataSourceName)
+	conn, err := getDatabaseConn(dbname)
 
 	if err != nil {
 		return
 	}
 
-	defer conn.Close()
-
 	rows, err := conn.Query(tableCountSql)
 	logger.Infof("Query Database: %s", tableCountSql)
 
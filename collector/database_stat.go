@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"time"
+)
+
+/**
+ *  按数据库统计的pg_stat_database计数器抓取器
+ */
+
+const (
+	//For GP5，旧的pg内核没有conflicts、temp_files、temp_bytes、deadlocks字段，不上报这4个指标
+	statDatabaseSql5 = `SELECT datname, xact_commit, xact_rollback, blks_read, blks_hit, tup_returned, tup_fetched
+			, tup_inserted, tup_updated, tup_deleted
+			FROM pg_stat_database where datname is not null;`
+	//For GP6
+	statDatabaseSql6 = `SELECT datname, xact_commit, xact_rollback, blks_read, blks_hit, tup_returned, tup_fetched
+			, tup_inserted, tup_updated, tup_deleted, conflicts, temp_files, temp_bytes, deadlocks
+			FROM pg_stat_database where datname is not null;`
+)
+
+var (
+	xactCommitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_xact_commit_total"),
+		"Number of transactions in this database that have been committed",
+		[]string{"datname"},
+		nil,
+	)
+
+	xactRollbackDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_xact_rollback_total"),
+		"Number of transactions in this database that have been rolled back",
+		[]string{"datname"},
+		nil,
+	)
+
+	blksReadDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_blks_read_total"),
+		"Number of disk blocks read in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	blksHitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_blks_hit_total"),
+		"Number of times disk blocks were found already in the buffer cache",
+		[]string{"datname"},
+		nil,
+	)
+
+	tupReturnedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_tup_returned_total"),
+		"Number of rows returned by queries in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	tupFetchedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_tup_fetched_total"),
+		"Number of rows fetched by queries in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	tupInsertedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_tup_inserted_total"),
+		"Number of rows inserted by queries in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	tupUpdatedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_tup_updated_total"),
+		"Number of rows updated by queries in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	tupDeletedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_tup_deleted_total"),
+		"Number of rows deleted by queries in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	conflictsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_conflicts_total"),
+		"Number of queries canceled due to conflicts with recovery in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	tempFilesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_temp_files_total"),
+		"Number of temporary files created by queries in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	tempBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_temp_bytes_total"),
+		"Total amount of data written to temporary files by queries in this database",
+		[]string{"datname"},
+		nil,
+	)
+
+	deadlocksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_deadlocks_total"),
+		"Number of deadlocks detected in this database",
+		[]string{"datname"},
+		nil,
+	)
+)
+
+func NewDatabaseStatScraper6() Scraper {
+	return databaseStatScraper6{}
+}
+
+func NewDatabaseStatScraper5() Scraper {
+	return databaseStatScraper5{}
+}
+
+type databaseStatScraper6 struct{}
+
+type databaseStatScraper5 struct{}
+
+func (databaseStatScraper6) Name() string {
+	return "database_stat_scraper"
+}
+
+func (databaseStatScraper5) Name() string {
+	return "database_stat_scraper"
+}
+
+func (databaseStatScraper6) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeDatabaseStat(db, statDatabaseSql6, true, ch)
+}
+
+func (databaseStatScraper5) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeDatabaseStat(db, statDatabaseSql5, false, ch)
+}
+
+/**
+* 函数：scrapeDatabaseStat
+* 功能：上报pg_stat_database计数器，extended为false时查询不包含conflicts、temp_files、temp_bytes、deadlocks字段
+ */
+func scrapeDatabaseStat(db *sql.DB, query string, extended bool, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", query)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var datname string
+		var xactCommit, xactRollback, blksRead, blksHit, tupReturned, tupFetched,
+			tupInserted, tupUpdated, tupDeleted, conflicts, tempFiles, tempBytes, deadlocks float64
+		dest := []interface{}{&datname, &xactCommit, &xactRollback, &blksRead, &blksHit, &tupReturned, &tupFetched,
+			&tupInserted, &tupUpdated, &tupDeleted}
+		if extended {
+			dest = append(dest, &conflicts, &tempFiles, &tempBytes, &deadlocks)
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(xactCommitDesc, prometheus.CounterValue, xactCommit, datname)
+		ch <- prometheus.MustNewConstMetric(xactRollbackDesc, prometheus.CounterValue, xactRollback, datname)
+		ch <- prometheus.MustNewConstMetric(blksReadDesc, prometheus.CounterValue, blksRead, datname)
+		ch <- prometheus.MustNewConstMetric(blksHitDesc, prometheus.CounterValue, blksHit, datname)
+		ch <- prometheus.MustNewConstMetric(tupReturnedDesc, prometheus.CounterValue, tupReturned, datname)
+		ch <- prometheus.MustNewConstMetric(tupFetchedDesc, prometheus.CounterValue, tupFetched, datname)
+		ch <- prometheus.MustNewConstMetric(tupInsertedDesc, prometheus.CounterValue, tupInserted, datname)
+		ch <- prometheus.MustNewConstMetric(tupUpdatedDesc, prometheus.CounterValue, tupUpdated, datname)
+		ch <- prometheus.MustNewConstMetric(tupDeletedDesc, prometheus.CounterValue, tupDeleted, datname)
+		if !extended {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(conflictsDesc, prometheus.CounterValue, conflicts, datname)
+		ch <- prometheus.MustNewConstMetric(tempFilesDesc, prometheus.CounterValue, tempFiles, datname)
+		ch <- prometheus.MustNewConstMetric(tempBytesDesc, prometheus.CounterValue, tempBytes, datname)
+		ch <- prometheus.MustNewConstMetric(deadlocksDesc, prometheus.CounterValue, deadlocks, datname)
+	}
+	return combineErr(errs...)
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	logger "github.com/prometheus/common/log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ *  按数据库遍历的公共方法
+ */
+
+const (
+	databaseListSql = `SELECT datname from pg_database where datallowconn and datname not in ('template0','template1','postgres');`
+)
+
+// 按数据库名称缓存的连接，在多次抓取之间复用
+var (
+	databaseConnsMu sync.Mutex
+	databaseConns   = make(map[string]*sql.DB)
+)
+
+/**
+* 函数：listDatabases
+* 功能：获取所有允许连接的用户数据库名称，并关闭已删除数据库的缓存连接
+ */
+func listDatabases(db *sql.DB) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", databaseListSql)
+	rows, err := db.QueryContext(ctx, databaseListSql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := make([]string, 0)
+	for rows.Next() {
+		var dbname string
+		if err = rows.Scan(&dbname); err != nil {
+			return nil, err
+		}
+		names = append(names, dbname)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	pruneDatabaseConns(names)
+	return names, nil
+}
+
+/**
+* 函数：getDatabaseConn
+* 功能：获取指定数据库的复用连接，调用方不需要关闭
+ */
+func getDatabaseConn(dbname string) (*sql.DB, error) {
+	databaseConnsMu.Lock()
+	defer databaseConnsMu.Unlock()
+	if conn, ok := databaseConns[dbname]; ok {
+		return conn, nil
+	}
+	conn, err := openDatabaseConn(dbname)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxIdleConns(1)
+	conn.SetMaxOpenConns(1)
+	databaseConns[dbname] = conn
+	return conn, nil
+}
+
+/**
+* 函数：pruneDatabaseConns
+* 功能：关闭不在names中的数据库缓存连接，postgres库不在用户数据库列表中，其缓存连接始终保留
+ */
+func pruneDatabaseConns(names []string) {
+	alive := map[string]bool{"postgres": true}
+	for _, dbname := range names {
+		alive[dbname] = true
+	}
+	databaseConnsMu.Lock()
+	defer databaseConnsMu.Unlock()
+	for dbname, conn := range databaseConns {
+		if !alive[dbname] {
+			_ = conn.Close()
+			delete(databaseConns, dbname)
+		}
+	}
+}
+
+/**
+* 函数：openDatabaseConn
+* 功能：基于GPDB_DATA_SOURCE_URL打开指定数据库的新连接，调用方负责关闭
+ */
+func openDatabaseConn(dbname string) (*sql.DB, error) {
+	dataSourceName := os.Getenv("GPDB_DATA_SOURCE_URL")
+	newDataSourceName := strings.Replace(dataSourceName, "/postgres", "/"+dbname, 1)
+	logger.Infof("Connection string is : %s", newDataSourceName)
+	return sql.Open("postgres", newDataSourceName)
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"time"
+)
+
+/**
+ *  分布式事务与两阶段提交抓取器
+ *  长时间处于prepared状态的事务会阻止整个集群的vacuum回收，需要及时告警
+ *  没有读取gp_distributed_log：segment只在收到COMMIT PREPARED后才写入分布式日志，prepared事务本身不会出现在其中，
+ *  要判断结果只能按distributed_xid扫描所有segment上的分布式日志，代价与gp_distributed_xacts的状态相比过高；
+ *  悬挂的prepared事务通过prepared_xacts_max_age_seconds发现
+ */
+
+const (
+	//master(content=-1)与所有primary上的prepared事务，没有prepared事务的segment上报0
+	preparedXactsSql = `SELECT c.content, count(p.gid), coalesce(extract(epoch from now()-min(p.prepared)), 0)
+		from gp_segment_configuration c
+		LEFT JOIN (SELECT -1 as content, gid, prepared from pg_prepared_xacts
+			UNION ALL
+			SELECT gp_execution_segment() as content, gid, prepared from gp_dist_random('pg_prepared_xacts')) p
+		ON p.content=c.content
+		where c.role='p'
+		GROUP BY c.content;`
+	distributedXactsSql = `SELECT state, count(*) from gp_distributed_xacts GROUP BY state;`
+)
+
+// 两阶段提交已开始、segment上可能存在prepared事务但结果尚未通知到所有segment的状态
+// Active Not Distributed、Active Distributed以及Notifying Abort No Prepared不包含在内
+var distributedXactsInDoubtStates = map[string]bool{
+	"Preparing":                     true,
+	"Prepared":                      true,
+	"Inserting Committed":           true,
+	"Inserted Committed":            true,
+	"Notifying Commit Prepared":     true,
+	"Inserting Forget Committed":    true,
+	"Inserted Forget Committed":     true,
+	"Notifying Abort Some Prepared": true,
+	"Notifying Abort Prepared":      true,
+	"Retry Commit Prepared":         true,
+	"Retry Abort Prepared":          true,
+}
+
+var (
+	preparedXactsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "prepared_xacts"),
+		"Number of prepared transactions on master (content -1) and each primary segment",
+		[]string{"content"}, nil,
+	)
+
+	preparedXactsMaxAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "prepared_xacts_max_age_seconds"),
+		"Age in seconds of the oldest prepared transaction on master (content -1) and each primary segment",
+		[]string{"content"}, nil,
+	)
+
+	distributedXactsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "distributed_xacts"),
+		"Number of distributed transactions in each state",
+		[]string{"state"}, nil,
+	)
+
+	distributedXactsInDoubtDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "distributed_xacts_in_doubt"),
+		"Number of distributed transactions in a two phase commit state after prepare was dispatched",
+		nil, nil,
+	)
+)
+
+func NewDistributedXactsScraper() Scraper {
+	return distributedXactsScraper{}
+}
+
+type distributedXactsScraper struct{}
+
+func (distributedXactsScraper) Name() string {
+	return "distributed_xacts_scraper"
+}
+
+func (distributedXactsScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	errP := scrapePreparedXacts(db, ch)
+	errD := scrapeDistributedXacts(db, ch)
+	return combineErr(errP, errD)
+}
+
+func scrapePreparedXacts(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", preparedXactsSql)
+	rows, err := db.QueryContext(ctx, preparedXactsSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var content string
+		var count, maxAge float64
+		err = rows.Scan(&content, &count, &maxAge)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(preparedXactsDesc, prometheus.GaugeValue, count, content)
+		ch <- prometheus.MustNewConstMetric(preparedXactsMaxAgeDesc, prometheus.GaugeValue, maxAge, content)
+	}
+	return combineErr(errs...)
+}
+
+func scrapeDistributedXacts(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", distributedXactsSql)
+	rows, err := db.QueryContext(ctx, distributedXactsSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	var inDoubt float64
+	for rows.Next() {
+		var state string
+		var count float64
+		err = rows.Scan(&state, &count)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if distributedXactsInDoubtStates[state] {
+			inDoubt += count
+		}
+		ch <- prometheus.MustNewConstMetric(distributedXactsDesc, prometheus.GaugeValue, count, state)
+	}
+	ch <- prometheus.MustNewConstMetric(distributedXactsInDoubtDesc, prometheus.GaugeValue, inDoubt)
+	return combineErr(errs...)
+}
@@ -8,11 +8,15 @@ import (
 
 /**
  *  实时动态内存抓取器，只对Pivotal Greenplum有效
+ *  主机级别读取memory_info中每个主机最新的一条记录，segment级别读取segment_now
+ *  （gpperfmon的iterators_*表在5.x中已移除，这里不再采集）
  */
 
 const (
 	//gpperfmon function
-	dynamicMemorySql = `select hostname, dynamic_memory_used_mb, dynamic_memory_available_mb from  memory_info order by 1 DESC limit 1;`
+	dynamicMemorySql = `select DISTINCT ON (hostname) hostname, dynamic_memory_used_mb, dynamic_memory_available_mb from memory_info
+		where ctime > now() - interval '10 minutes' order by hostname, ctime DESC;`
+	segmentDynamicMemorySql = `select dbid, hostname, dynamic_memory_used, dynamic_memory_available from segment_now;`
 )
 
 var (
@@ -27,6 +31,18 @@ var (
 		"The amount of additional dynamic memory (in MB) available to the query processes running on this segment host",
 		[]string{"hostname"}, nil,
 	)
+
+	segmentDynamicMemUsedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_dynamic_memory_used_bytes"),
+		"The amount of dynamic memory in bytes allocated to query processes running on this segment",
+		[]string{"hostname", "dbid"}, nil,
+	)
+
+	segmentDynamicMemAvailableDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_dynamic_memory_available_bytes"),
+		"The amount of additional dynamic memory in bytes available to the query processes running on this segment",
+		[]string{"hostname", "dbid"}, nil,
+	)
 )
 
 func NewDynamicMemoryScraper() Scraper {
@@ -40,8 +56,14 @@ func (dynamicMemoryScraper) Name() string {
 }
 
 func (dynamicMemoryScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	errH := scrapeHostDynamicMemory(db, ch)
+	errS := scrapeSegmentDynamicMemory(db, ch)
+	return combineErr(errH, errS)
+}
+
+func scrapeHostDynamicMemory(db *sql.DB, ch chan<- prometheus.Metric) error {
 	rows, err := db.Query(dynamicMemorySql)
-	logger.Infof("Query Database: %s",dynamicMemorySql)
+	logger.Infof("Query Database: %s", dynamicMemorySql)
 	if err != nil {
 		return err
 	}
@@ -60,3 +82,25 @@ func (dynamicMemoryScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) erro
 	}
 	return combineErr(errs...)
 }
+
+func scrapeSegmentDynamicMemory(db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.Query(segmentDynamicMemorySql)
+	logger.Infof("Query Database: %s", segmentDynamicMemorySql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var dbID, hostname string
+		var used, available float64
+		err = rows.Scan(&dbID, &hostname, &used, &available)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(segmentDynamicMemUsedDesc, prometheus.GaugeValue, used, hostname, dbID)
+		ch <- prometheus.MustNewConstMetric(segmentDynamicMemAvailableDesc, prometheus.GaugeValue, available, hostname, dbID)
+	}
+	return combineErr(errs...)
+}
@@ -0,0 +1,248 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/**
+ *  外部表与gpfdist加载错误抓取器
+ *  按数据库统计可读/可写外部表数量、开启LOG ERRORS的外部表错误日志行数，以及location无法连通的外部表数量
+ *  错误日志行数按cmdtime水位线增量累计，首次发现的表只初始化水位线
+ *  连通性从exporter所在主机检测，而实际连接gpfdist的是各segment，两者网络位置不同时结果只能作为参考
+ */
+
+const (
+	//For GP5，旧版本通过fmterrtbl记录错误日志表，location在6.x中才拆分为urilocation与execlocation
+	externalTablesSql5 = `SELECT n.nspname, c.relname, e.writable, e.fmterrtbl is not null as logerrors, e.location
+		from pg_exttable e JOIN pg_class c ON c.oid=e.reloid JOIN pg_namespace n ON n.oid=c.relnamespace;`
+	//For GP6
+	externalTablesSql6 = `SELECT n.nspname, c.relname, e.writable, e.logerrors, e.urilocation
+		from pg_exttable e JOIN pg_class c ON c.oid=e.reloid JOIN pg_namespace n ON n.oid=c.relnamespace;`
+	errorLogSql = `SELECT coalesce(sum(case when cmdtime > $2 then 1 else 0 end), 0), max(cmdtime) from gp_read_error_log($1);`
+)
+
+// 检测外部表location连通性的超时时间
+const externalLocationTimeout = time.Second * 2
+
+var (
+	externalTablesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "external_tables"),
+		"Number of readable and writable external tables of each database",
+		[]string{"dbname", "type"}, nil,
+	)
+
+	externalErrorRowsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "external_table_error_rows_total"),
+		"Number of rows written to the error log of each external table since the exporter started",
+		[]string{"dbname", "schema", "table"}, nil,
+	)
+
+	externalUnreachableDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "external_tables_unreachable"),
+		"Number of external tables with at least one gpfdist/http location unreachable over TCP from the exporter host (not from the segments, which are the actual gpfdist clients)",
+		[]string{"dbname"}, nil,
+	)
+)
+
+type externalTableKey struct {
+	dbname, schema, table string
+}
+
+type externalTable struct {
+	schema, table string
+	writable      bool
+	logErrors     bool
+	locations     pq.StringArray
+}
+
+func NewExternalTableScraper6() Scraper {
+	return newExternalTableScraper(externalTablesSql6)
+}
+
+func NewExternalTableScraper5() Scraper {
+	return newExternalTableScraper(externalTablesSql5)
+}
+
+func newExternalTableScraper(query string) *externalTableScraper {
+	return &externalTableScraper{
+		query:      query,
+		watermarks: make(map[externalTableKey]time.Time),
+		errorRows:  make(map[externalTableKey]float64),
+	}
+}
+
+type externalTableScraper struct {
+	mu         sync.Mutex
+	query      string
+	watermarks map[externalTableKey]time.Time
+	errorRows  map[externalTableKey]float64
+}
+
+func (*externalTableScraper) Name() string {
+	return "external_table_scraper"
+}
+
+func (s *externalTableScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := listDatabases(db)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	reachable := make(map[string]bool)
+	seen := make(map[externalTableKey]bool)
+	//外部表列表读取失败的数据库保留原有的计数
+	keep := make(map[string]bool)
+	for _, dbname := range names {
+		if err := s.scrapeDatabaseExternalTables(dbname, reachable, seen, ch); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+			keep[dbname] = true
+		}
+	}
+	for key := range s.watermarks {
+		if !seen[key] && !keep[key.dbname] {
+			delete(s.watermarks, key)
+			delete(s.errorRows, key)
+		}
+	}
+	for key, rows := range s.errorRows {
+		ch <- prometheus.MustNewConstMetric(externalErrorRowsDesc, prometheus.CounterValue, rows, key.dbname, key.schema, key.table)
+	}
+	return combineErr(errs...)
+}
+
+func (s *externalTableScraper) scrapeDatabaseExternalTables(dbname string, reachable map[string]bool, seen map[externalTableKey]bool, ch chan<- prometheus.Metric) error {
+	conn, err := getDatabaseConn(dbname)
+	if err != nil {
+		return err
+	}
+	tables, err := s.listExternalTables(conn)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	var readable, writable, unreachable float64
+	for _, t := range tables {
+		if t.writable {
+			writable++
+		} else {
+			readable++
+		}
+		if !locationsReachable(t.locations, reachable) {
+			unreachable++
+		}
+		if t.logErrors && !t.writable {
+			key := externalTableKey{dbname: dbname, schema: t.schema, table: t.table}
+			seen[key] = true
+			if err := s.readErrorLog(conn, key); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(externalTablesDesc, prometheus.GaugeValue, readable, dbname, "readable")
+	ch <- prometheus.MustNewConstMetric(externalTablesDesc, prometheus.GaugeValue, writable, dbname, "writable")
+	ch <- prometheus.MustNewConstMetric(externalUnreachableDesc, prometheus.GaugeValue, unreachable, dbname)
+	return combineErr(errs...)
+}
+
+func (s *externalTableScraper) listExternalTables(conn *sql.DB) ([]externalTable, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", s.query)
+	rows, err := conn.QueryContext(ctx, s.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make([]externalTable, 0)
+	for rows.Next() {
+		var t externalTable
+		if err = rows.Scan(&t.schema, &t.table, &t.writable, &t.logErrors, &t.locations); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+/**
+* 函数：readErrorLog
+* 功能：读取外部表水位线之后新增的错误日志行数并累计，首次发现的表只初始化水位线
+ */
+func (s *externalTableScraper) readErrorLog(conn *sql.DB, key externalTableKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", errorLogSql)
+	watermark, seen := s.watermarks[key]
+	var count float64
+	var lastCmdTime pq.NullTime
+	err := conn.QueryRowContext(ctx, errorLogSql, pq.QuoteIdentifier(key.schema)+"."+pq.QuoteIdentifier(key.table), watermark).
+		Scan(&count, &lastCmdTime)
+	if err != nil {
+		return err
+	}
+	if seen {
+		s.errorRows[key] += count
+	} else {
+		s.errorRows[key] = 0
+	}
+	if lastCmdTime.Valid && lastCmdTime.Time.After(watermark) {
+		s.watermarks[key] = lastCmdTime.Time
+	} else {
+		s.watermarks[key] = watermark
+	}
+	return nil
+}
+
+/**
+* 函数：locationsReachable
+* 功能：检测gpfdist/gpfdists/http location是否可以建立TCP连接，同一次抓取中的结果缓存在reachable中
+ */
+func locationsReachable(locations []string, reachable map[string]bool) bool {
+	for _, location := range locations {
+		u, err := url.Parse(location)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		var port string
+		switch u.Scheme {
+		case "gpfdist", "gpfdists":
+			port = "8080"
+		case "http":
+			port = "80"
+		default:
+			continue
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), port)
+		}
+		ok, checked := reachable[host]
+		if !checked {
+			conn, err := net.DialTimeout("tcp", host, externalLocationTimeout)
+			ok = err == nil
+			if ok {
+				_ = conn.Close()
+			} else {
+				logger.Warnf("external table location %s unreachable: %v", location, err)
+			}
+			reachable[host] = ok
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
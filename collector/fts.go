@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"sync"
+	"time"
+)
+
+/**
+ *  FTS故障探测与segment故障切换历史抓取器
+ *  gp_configuration_history中每一条记录对应FTS对segment状态/角色/模式的一次变更
+ *  变更记录的desc为自由文本，6.x中仅状态变更也会写入"update role, status, and mode"，因此不解析desc，
+ *  最近一次故障切换时间取当前role<>preferred_role的segment在gp_configuration_history中最近一次变更的时间；
+ *  切回preferred_role后该记录不再参与计算，因此同时比较两次抓取之间每个dbid的role作为补充
+ */
+
+const (
+	ftsHistorySql       = `SELECT dbid, count(*), extract(epoch from max(time)) from gp_configuration_history GROUP BY dbid;`
+	segmentRolesSql     = `SELECT dbid, role from gp_segment_configuration;`
+	notPreferredRoleSql = `SELECT count(*) from gp_segment_configuration where role<>preferred_role;`
+	ftsProbeIntervalSql = `SELECT setting from pg_settings where name='gp_fts_probe_interval';`
+	ftsProbeTimeoutSql  = `SELECT setting from pg_settings where name='gp_fts_probe_timeout';`
+	lastFailoverSql     = `SELECT coalesce(extract(epoch from max(h.time)), 0) from gp_configuration_history h
+		JOIN gp_segment_configuration c ON c.dbid=h.dbid where c.role<>c.preferred_role;`
+)
+
+var (
+	ftsChangesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "fts_segment_changes_total"),
+		"Number of status/role/mode changes recorded by FTS in gp_configuration_history for each segment",
+		[]string{"dbid"},
+		nil,
+	)
+
+	ftsLastChangeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "fts_segment_last_change_time_seconds"),
+		"Timestamp of the last change recorded by FTS for each segment",
+		[]string{"dbid"},
+		nil,
+	)
+
+	lastFailoverDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "last_failover_time_seconds"),
+		"Timestamp of the last change in gp_configuration_history of segments not in their preferred role, or of the last role change observed by the exporter if later, 0 if none",
+		nil,
+		nil,
+	)
+
+	notPreferredRoleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segments_not_preferred_role"),
+		"Number of segments currently not running in their preferred role",
+		nil,
+		nil,
+	)
+
+	ftsProbeIntervalDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "fts_probe_interval_seconds"),
+		"Value of gp_fts_probe_interval",
+		nil,
+		nil,
+	)
+
+	ftsProbeTimeoutDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "fts_probe_timeout_seconds"),
+		"Value of gp_fts_probe_timeout",
+		nil,
+		nil,
+	)
+)
+
+func NewFtsScraper() Scraper {
+	return &ftsScraper{}
+}
+
+type ftsScraper struct {
+	mu           sync.Mutex
+	roles        map[string]string
+	lastFailover float64
+}
+
+func (*ftsScraper) Name() string {
+	return "fts_scraper"
+}
+
+func (s *ftsScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	lastChanges, errH := scrapeFtsHistory(db, ch)
+	errF := s.scrapeLastFailover(db, lastChanges, ch)
+	errR := scrapeSingleValue(db, notPreferredRoleSql, notPreferredRoleDesc, ch)
+	errI := scrapeSingleValue(db, ftsProbeIntervalSql, ftsProbeIntervalDesc, ch)
+	errT := scrapeSingleValue(db, ftsProbeTimeoutSql, ftsProbeTimeoutDesc, ch)
+
+	return combineErr(errH, errF, errR, errI, errT)
+}
+
+/**
+* 函数：scrapeFtsHistory
+* 功能：上报每个segment的变更次数与最近一次变更时间，并返回按dbid的最近一次变更时间
+ */
+func scrapeFtsHistory(db *sql.DB, ch chan<- prometheus.Metric) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", ftsHistorySql)
+	rows, err := db.QueryContext(ctx, ftsHistorySql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	lastChanges := make(map[string]float64)
+	for rows.Next() {
+		var dbID string
+		var changes, lastChange float64
+		err = rows.Scan(&dbID, &changes, &lastChange)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(ftsChangesDesc, prometheus.CounterValue, changes, dbID)
+		ch <- prometheus.MustNewConstMetric(ftsLastChangeDesc, prometheus.GaugeValue, lastChange, dbID)
+		lastChanges[dbID] = lastChange
+	}
+	return lastChanges, combineErr(errs...)
+}
+
+/**
+* 函数：scrapeLastFailover
+* 功能：从gp_configuration_history读取当前未处于preferred_role的segment的最近一次变更时间，
+*      并与exporter比较两次抓取之间role变化得到的时间取较大值；首次抓取只记录role
+ */
+func (s *ftsScraper) scrapeLastFailover(db *sql.DB, lastChanges map[string]float64, ch chan<- prometheus.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", lastFailoverSql)
+	var lastFailover float64
+	if err := db.QueryRowContext(ctx, lastFailoverSql).Scan(&lastFailover); err != nil {
+		return err
+	}
+	logger.Infof("Query Database: %s", segmentRolesSql)
+	rows, err := db.QueryContext(ctx, segmentRolesSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	roles := make(map[string]string)
+	for rows.Next() {
+		var dbID, role string
+		if err = rows.Scan(&dbID, &role); err != nil {
+			return err
+		}
+		roles[dbID] = role
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for dbID, role := range roles {
+		previous, ok := s.roles[dbID]
+		if !ok || previous == role {
+			continue
+		}
+		failover := float64(time.Now().Unix())
+		if lastChange, ok := lastChanges[dbID]; ok {
+			failover = lastChange
+		}
+		if failover > s.lastFailover {
+			s.lastFailover = failover
+		}
+	}
+	s.roles = roles
+	if s.lastFailover > lastFailover {
+		lastFailover = s.lastFailover
+	}
+	ch <- prometheus.MustNewConstMetric(lastFailoverDesc, prometheus.GaugeValue, lastFailover)
+	return nil
+}
+
+/**
+* 函数：scrapeSingleValue
+* 功能：执行只返回一个数值的查询，并以Gauge上报
+ */
+func scrapeSingleValue(db *sql.DB, query string, desc *prometheus.Desc, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", query)
+	var value float64
+	if err := db.QueryRowContext(ctx, query).Scan(&value); err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	return nil
+}
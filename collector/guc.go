@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ *  GUC配置漂移抓取器
+ *  通过gp_toolkit.gp_param_setting读取master(content=-1)与所有segment上的参数值，segment与master不一致时上报mismatch
+ *  5.x/6.x的pg内核没有pg_settings.pending_restart字段，需要重启生效的参数只能通过master与segment的差异发现
+ */
+
+const (
+	gucSettingSql = `SELECT paramsegment, paramvalue from gp_toolkit.gp_param_setting($1);`
+)
+
+var (
+	gucNames = kingpin.Flag("collect.guc.names",
+		"comma separated GUC names checked for drift between master and segments, avoid GUCs set differently on master on purpose such as max_connections").
+		Default("work_mem,statement_mem,max_statement_mem,gp_resource_manager,optimizer").String()
+)
+
+var (
+	gucValueDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "guc_value"),
+		"Numeric value of the GUC on master (content -1) and each segment, memory in bytes and time in seconds",
+		[]string{"name", "content"}, nil,
+	)
+
+	gucMismatchDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "guc_mismatch"),
+		"Whether the GUC value on this segment differs from the master",
+		[]string{"name", "content"}, nil,
+	)
+)
+
+// 带单位的参数值，如 128MB、8kB、1min、on
+var gucValueRegex = regexp.MustCompile(`^(-?[0-9.]+)\s*([a-zA-Z]*)$`)
+
+var gucUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"kB":  1024,
+	"MB":  1024 * 1024,
+	"GB":  1024 * 1024 * 1024,
+	"TB":  1024 * 1024 * 1024 * 1024,
+	"ms":  0.001,
+	"s":   1,
+	"min": 60,
+	"h":   3600,
+	"d":   86400,
+}
+
+func NewGucScraper() Scraper {
+	return gucScraper{}
+}
+
+type gucScraper struct{}
+
+func (gucScraper) Name() string {
+	return "guc_scraper"
+}
+
+func (gucScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	errs := make([]error, 0)
+	for _, name := range strings.Split(*gucNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := scrapeGucSetting(db, name, ch); err != nil {
+			errs = append(errs, fmt.Errorf("guc %s: %v", name, err))
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeGucSetting(db *sql.DB, name string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", gucSettingSql)
+	rows, err := db.QueryContext(ctx, gucSettingSql, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	values := make(map[string]string)
+	for rows.Next() {
+		var content, value string
+		err = rows.Scan(&content, &value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values[content] = value
+		if v, ok := parseGucValue(value); ok {
+			ch <- prometheus.MustNewConstMetric(gucValueDesc, prometheus.GaugeValue, v, name, content)
+		}
+	}
+
+	master, ok := values["-1"]
+	if !ok {
+		return combineErr(append(errs, fmt.Errorf("master value not found"))...)
+	}
+	for content, value := range values {
+		if content == "-1" {
+			continue
+		}
+		var mismatch float64
+		if value != master {
+			mismatch = 1
+		}
+		ch <- prometheus.MustNewConstMetric(gucMismatchDesc, prometheus.GaugeValue, mismatch, name, content)
+	}
+	return combineErr(errs...)
+}
+
+/**
+* 函数：parseGucValue
+* 功能：将参数值转换为数值，内存单位转换为字节、时间单位转换为秒，on/off转换为1/0
+ */
+func parseGucValue(value string) (float64, bool) {
+	switch strings.ToLower(value) {
+	case "on", "true":
+		return 1, true
+	case "off", "false":
+		return 0, true
+	}
+	match := gucValueRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, false
+	}
+	unit, ok := gucUnits[match[2]]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * unit, true
+}
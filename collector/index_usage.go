@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"time"
+)
+
+/**
+ *  索引使用情况抓取器
+ *  master与各个segment上的pg_stat_all_indexes汇总后，按数据库上报大索引的扫描次数与从未被扫描的索引数量
+ *  每次抓取都要在所有数据库的segment上计算全部索引的大小，开销较大，需通过--collect.index开启
+ */
+
+const (
+	//master与所有segment上的索引统计
+	indexStatsFrom = `(SELECT indexrelid, schemaname, relname, indexrelname, idx_scan, idx_tup_read from pg_stat_all_indexes
+			UNION ALL
+			SELECT indexrelid, schemaname, relname, indexrelname, idx_scan, idx_tup_read from gp_dist_random('pg_stat_all_indexes')) s`
+	indexStatsFilter = `s.schemaname not in ('pg_catalog','information_schema','gp_toolkit','pg_aoseg') and s.schemaname not like 'pg_toast%'`
+
+	//在segment上一次性计算所有索引的大小，避免在master上对每个索引调用pg_relation_size时分别下发到segment
+	indexSizeFrom = `(SELECT oid as indexrelid, sum(pg_relation_size(oid)) as size from gp_dist_random('pg_class')
+			where relkind='i' GROUP BY oid) z`
+
+	indexUsageSql = `SELECT s.schemaname, s.relname, s.indexrelname, sum(s.idx_scan), sum(s.idx_tup_read), max(z.size)
+		from ` + indexStatsFrom + ` JOIN ` + indexSizeFrom + ` ON z.indexrelid=s.indexrelid
+		where ` + indexStatsFilter + `
+		GROUP BY s.indexrelid, s.schemaname, s.relname, s.indexrelname
+		HAVING max(z.size) >= $1;`
+
+	//唯一索引用于约束检查，不计入从未被扫描的索引
+	unusedIndexSql = `SELECT count(*) from (SELECT s.indexrelid
+		from ` + indexStatsFrom + `
+		where ` + indexStatsFilter + `
+		and s.indexrelid in (SELECT indexrelid from pg_index where not indisunique)
+		GROUP BY s.indexrelid HAVING sum(s.idx_scan)=0) t;`
+)
+
+var (
+	collectIndexUsage = kingpin.Flag("collect.index",
+		"collect index usage of every database, computing the size of all indexes on segments at each scrape").Default("false").Bool()
+	indexMinSizeMB = kingpin.Flag("collect.index.min-size-mb",
+		"only indexes larger than this size (MB) are reported individually").Default("100").Int()
+)
+
+var (
+	indexScanDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "index_scan_total"),
+		"Number of index scans initiated on this index, summed over master and segments",
+		[]string{"dbname", "schema", "table", "index"},
+		nil,
+	)
+
+	indexTupReadDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "index_tup_read_total"),
+		"Number of index entries returned by scans on this index, summed over master and segments",
+		[]string{"dbname", "schema", "table", "index"},
+		nil,
+	)
+
+	indexSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "index_mb_size"),
+		"Total MB size of this index in the file system",
+		[]string{"dbname", "schema", "table", "index"},
+		nil,
+	)
+
+	unusedIndexCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "index_unused_count"),
+		"Number of non-unique indexes that have never been scanned of each database",
+		[]string{"dbname"},
+		nil,
+	)
+)
+
+func NewIndexUsageScraper() Scraper {
+	return indexUsageScraper{}
+}
+
+type indexUsageScraper struct{}
+
+func (indexUsageScraper) Name() string {
+	return "index_usage_scraper"
+}
+
+func (indexUsageScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	if !*collectIndexUsage {
+		return nil
+	}
+	names, err := listDatabases(db)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, dbname := range names {
+		if err := scrapeDatabaseIndexUsage(dbname, ch); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeDatabaseIndexUsage(dbname string, ch chan<- prometheus.Metric) error {
+	conn, err := getDatabaseConn(dbname)
+	if err != nil {
+		return err
+	}
+
+	errI := scrapeIndexUsage(conn, dbname, ch)
+	errU := scrapeUnusedIndex(conn, dbname, ch)
+
+	return combineErr(errI, errU)
+}
+
+func scrapeIndexUsage(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", indexUsageSql)
+	rows, err := conn.QueryContext(ctx, indexUsageSql, int64(*indexMinSizeMB)*1024*1024)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var schema, table, index string
+		var scan, tupRead, size float64
+		err = rows.Scan(&schema, &table, &index, &scan, &tupRead, &size)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(indexScanDesc, prometheus.CounterValue, scan, dbname, schema, table, index)
+		ch <- prometheus.MustNewConstMetric(indexTupReadDesc, prometheus.CounterValue, tupRead, dbname, schema, table, index)
+		ch <- prometheus.MustNewConstMetric(indexSizeDesc, prometheus.GaugeValue, size/(1024*1024), dbname, schema, table, index)
+	}
+	return combineErr(errs...)
+}
+
+func scrapeUnusedIndex(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", unusedIndexSql)
+	var count float64
+	if err := conn.QueryRowContext(ctx, unusedIndexSql).Scan(&count); err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(unusedIndexCountDesc, prometheus.GaugeValue, count, dbname)
+	return nil
+}
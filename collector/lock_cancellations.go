@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"sync"
+	"time"
+)
+
+/**
+ *  死锁与锁超时抓取器
+ *  pg_stat_database.deadlocks已由database_stat_scraper上报，这里从master的ERROR日志按原因统计被取消的语句
+ *  （本地死锁、全局死锁检测器、lock_timeout、statement_timeout），5.x没有lock_timeout与全局死锁检测器
+ *  全局死锁检测器通过pg_cancel_backend_msg取消语句，记录为57014及"cancelled by global deadlock detector"，而不是40P01
+ *  只读取gp_toolkit中master的日志外部表，不扫描segment日志；按logtime水位线增量读取，首次抓取只初始化水位线
+ */
+
+const (
+	lockCancelWatermarkSql = `SELECT now();`
+	lockCancelSql          = `SELECT coalesce(logdatabase, ''),
+		case when logstate='57014' and logmessage like '%global deadlock detector%' then 'global_deadlock'
+			when logstate='40P01' then 'deadlock'
+			when logstate='55P03' then 'lock_timeout'
+			else 'statement_timeout' end,
+		count(*), max(logtime)
+		from gp_toolkit.__gp_log_master_ext
+		where logtime > $1 and logseverity='ERROR'
+			and (logstate='40P01'
+				or (logstate='55P03' and logmessage like '%lock timeout%')
+				or (logstate='57014' and (logmessage like '%statement timeout%' or logmessage like '%global deadlock detector%')))
+		GROUP BY 1,2;`
+	//For GP6
+	gddEnabledSql = `SELECT (setting='on')::int from pg_settings where name='gp_enable_global_deadlock_detector';`
+	gddPeriodSql  = `SELECT setting::float from pg_settings where name='gp_global_deadlock_detector_period';`
+)
+
+var (
+	lockCancellationsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "lock_cancellations_total"),
+		"Number of statements canceled by deadlock, global deadlock detector, lock_timeout or statement_timeout since the exporter started",
+		[]string{"dbname", "reason"}, nil,
+	)
+
+	gddEnabledDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "global_deadlock_detector_enabled"),
+		"Value of gp_enable_global_deadlock_detector",
+		nil, nil,
+	)
+
+	gddPeriodDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "global_deadlock_detector_period_seconds"),
+		"Value of gp_global_deadlock_detector_period",
+		nil, nil,
+	)
+)
+
+type lockCancelKey struct {
+	dbname, reason string
+}
+
+func NewLockCancelScraper6() Scraper {
+	return &lockCancelScraper{gdd: true, counts: make(map[lockCancelKey]float64)}
+}
+
+func NewLockCancelScraper5() Scraper {
+	return &lockCancelScraper{counts: make(map[lockCancelKey]float64)}
+}
+
+type lockCancelScraper struct {
+	mu        sync.Mutex
+	gdd       bool
+	watermark time.Time
+	counts    map[lockCancelKey]float64
+}
+
+func (*lockCancelScraper) Name() string {
+	return "lock_cancel_scraper"
+}
+
+func (s *lockCancelScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]error, 0)
+	errs = append(errs, s.readLockCancellations(db))
+	for key, count := range s.counts {
+		ch <- prometheus.MustNewConstMetric(lockCancellationsDesc, prometheus.CounterValue, count, key.dbname, key.reason)
+	}
+	if s.gdd {
+		errs = append(errs, scrapeSingleValue(db, gddEnabledSql, gddEnabledDesc, ch))
+		errs = append(errs, scrapeSingleValue(db, gddPeriodSql, gddPeriodDesc, ch))
+	}
+	return combineErr(errs...)
+}
+
+func (s *lockCancelScraper) readLockCancellations(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *logQueryTimeout)
+	defer cancel()
+
+	if s.watermark.IsZero() {
+		logger.Infof("Query Database: %s", lockCancelWatermarkSql)
+		return db.QueryRowContext(ctx, lockCancelWatermarkSql).Scan(&s.watermark)
+	}
+
+	logger.Infof("Query Database: %s", lockCancelSql)
+	rows, err := db.QueryContext(ctx, lockCancelSql, s.watermark)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	watermark := s.watermark
+	for rows.Next() {
+		var key lockCancelKey
+		var count float64
+		var lastLogTime time.Time
+		err = rows.Scan(&key.dbname, &key.reason, &count, &lastLogTime)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.counts[key] += count
+		if lastLogTime.After(watermark) {
+			watermark = lastLogTime
+		}
+	}
+	s.watermark = watermark
+	return combineErr(errs...)
+}
@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ *  日志告警抓取器
+ *  开源版读取gp_toolkit.gp_log_system（会扫描master与所有segment的全部日志文件，logtime条件无法减少扫描量），
+ *  Pivotal版读取gpperfmon的log_alert_history；exporter不在集群中创建任何对象
+ *  按logsegment分别维护logtime水位线增量读取，避免segment时钟偏差导致日志被跳过；新出现的segment只初始化水位线
+ */
+
+const (
+	logAlertSql = `SELECT l.logsegment, l.logseverity, coalesce(l.logstate, ''), count(*), max(l.logtime), w.mark is null
+		from %s l LEFT JOIN (%s) w(segment, mark) ON w.segment=l.logsegment
+		where (w.mark is null or l.logtime > w.mark)
+			and l.logseverity in ('WARNING','ERROR','FATAL','PANIC') and ($1 = '' or l.logmessage !~ $1)
+		GROUP BY 1,2,3,6;`
+
+	logSystemSource       = `gp_toolkit.gp_log_system`
+	logAlertHistorySource = `log_alert_history`
+)
+
+var (
+	logExcludeMessage = kingpin.Flag("collect.log.exclude-message",
+		"regex (PostgreSQL syntax) of noisy log messages excluded from log message counters").Default("").String()
+	logQueryTimeout = kingpin.Flag("collect.log.timeout",
+		"timeout of queries reading log files of master and segments").Default("60s").Duration()
+)
+
+var (
+	logMessagesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "log_messages_total"),
+		"Number of WARNING/ERROR/FATAL/PANIC log messages since the exporter started",
+		[]string{"severity", "sqlstate", "segment"}, nil,
+	)
+)
+
+type logAlertKey struct {
+	severity, sqlstate, segment string
+}
+
+func NewLogSystemScraper() Scraper {
+	return newLogAlertScraper(logSystemSource)
+}
+
+func NewLogAlertHistoryScraper() Scraper {
+	return newLogAlertScraper(logAlertHistorySource)
+}
+
+func newLogAlertScraper(source string) *logAlertScraper {
+	return &logAlertScraper{
+		source:     source,
+		watermarks: make(map[string]time.Time),
+		counts:     make(map[logAlertKey]float64),
+	}
+}
+
+type logAlertScraper struct {
+	mu         sync.Mutex
+	source     string
+	watermarks map[string]time.Time
+	counts     map[logAlertKey]float64
+}
+
+func (*logAlertScraper) Name() string {
+	return "log_alert_scraper"
+}
+
+func (s *logAlertScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.readLogAlerts(db)
+	for key, count := range s.counts {
+		ch <- prometheus.MustNewConstMetric(logMessagesDesc, prometheus.CounterValue, count, key.severity, key.sqlstate, key.segment)
+	}
+	return err
+}
+
+func (s *logAlertScraper) readLogAlerts(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *logQueryTimeout)
+	defer cancel()
+
+	marks, args := watermarkValues(s.watermarks, 2)
+	query := fmt.Sprintf(logAlertSql, s.source, marks)
+	logger.Infof("Query Database: %s", query)
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{*logExcludeMessage}, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	watermarks := make(map[string]time.Time, len(s.watermarks))
+	for segment, mark := range s.watermarks {
+		watermarks[segment] = mark
+	}
+	for rows.Next() {
+		var key logAlertKey
+		var count float64
+		var lastLogTime time.Time
+		var newSegment bool
+		err = rows.Scan(&key.segment, &key.severity, &key.sqlstate, &count, &lastLogTime, &newSegment)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !newSegment {
+			s.counts[key] += count
+		}
+		if lastLogTime.After(watermarks[key.segment]) {
+			watermarks[key.segment] = lastLogTime
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	s.watermarks = watermarks
+	return combineErr(errs...)
+}
+
+/**
+* 函数：watermarkValues
+* 功能：把按logsegment的水位线转换为VALUES子查询，参数编号从first开始；没有水位线时返回不包含任何行的子查询
+ */
+func watermarkValues(watermarks map[string]time.Time, first int) (string, []interface{}) {
+	if len(watermarks) == 0 {
+		return `SELECT null::text, null::timestamptz where false`, nil
+	}
+	values := make([]string, 0, len(watermarks))
+	args := make([]interface{}, 0, len(watermarks)*2)
+	for segment, mark := range watermarks {
+		n := first + len(args)
+		values = append(values, fmt.Sprintf("($%d::text, $%d::timestamptz)", n, n+1))
+		args = append(args, segment, mark)
+	}
+	return "VALUES " + strings.Join(values, ", "), args
+}
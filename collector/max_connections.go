@@ -1,20 +1,34 @@
 package collector
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
 	logger "github.com/prometheus/common/log"
+	"time"
 )
 
 /**
  *  最大连接数抓取器
+ *  同时上报按角色、按数据库的连接数与连接限制，以及每个segment上的QE进程数与max_connections
  */
 
 const (
 	maxConnectionsSql = `show max_connections`
 	suReservedSql     = `show superuser_reserved_connections`
+	totalBackendsSql  = `SELECT count(*) from pg_stat_activity`
+	roleConnSql       = `SELECT r.rolname, r.rolconnlimit, count(a.usename) from pg_roles r
+		LEFT JOIN pg_stat_activity a ON a.usename=r.rolname
+		where r.rolcanlogin GROUP BY 1,2;`
+	databaseConnSql = `SELECT d.datname, d.datconnlimit, count(a.datid) from pg_database d
+		LEFT JOIN pg_stat_activity a ON a.datid=d.oid
+		where d.datallowconn GROUP BY 1,2;`
+	//每个segment上的后端进程数（包含exporter自身的QE进程）
+	segmentConnSql = `SELECT content, max(max_conn), count(*) from (SELECT gp_execution_segment() as content,
+		current_setting('max_connections')::int as max_conn, pg_stat_get_backend_idset() as backend
+		from gp_dist_random('gp_id')) t GROUP BY content;`
 )
 
 var (
@@ -23,6 +37,54 @@ var (
 		"Max connection of greenPlum cluster",
 		nil, nil,
 	)
+
+	connHeadroomDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "connection_headroom"),
+		"Remaining connections of greenPlum cluster before max_connections minus superuser_reserved_connections is reached",
+		nil, nil,
+	)
+
+	roleConnDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "role_connections"),
+		"Current connections of each login role",
+		[]string{"rolname"}, nil,
+	)
+
+	roleConnHeadroomDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "role_connection_headroom"),
+		"Remaining connections of each login role before rolconnlimit is reached, only for roles with a limit",
+		[]string{"rolname"}, nil,
+	)
+
+	databaseConnDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "database_connections"),
+		"Current connections of each database",
+		[]string{"datname"}, nil,
+	)
+
+	databaseConnLimitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "database_connection_limit"),
+		"Connection limit of each database, -1 means no limit",
+		[]string{"datname"}, nil,
+	)
+
+	databaseConnHeadroomDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "database_connection_headroom"),
+		"Remaining connections of each database before datconnlimit is reached, only for databases with a limit",
+		[]string{"datname"}, nil,
+	)
+
+	segmentMaxConnDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_max_connections"),
+		"max_connections of each primary segment",
+		[]string{"content"}, nil,
+	)
+
+	segmentBackendsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_backends"),
+		"Current backends (QE processes) of each primary segment, including the exporter's own",
+		[]string{"content"}, nil,
+	)
 )
 
 func NewMaxConnScraper() Scraper {
@@ -40,18 +102,26 @@ func (maxConnScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
 	if err != nil {
 		return err
 	}
-	reserved, err := showConnections(db, suReservedSql)
-	if err != nil {
-		logger.Warn(err.Error())
+	//这里的最大连接数应为max_connections减去superuser_reserved_connections，读取失败时不上报
+	reserved, errR := showConnections(db, suReservedSql)
+	total, errT := showConnections(db, totalBackendsSql)
+	if errR == nil {
+		ch <- prometheus.MustNewConstMetric(maxConnDesc, prometheus.GaugeValue, maxConn-reserved)
+		if errT == nil {
+			ch <- prometheus.MustNewConstMetric(connHeadroomDesc, prometheus.GaugeValue, maxConn-reserved-total)
+		}
 	}
-	//这里的最大连接数应为max_connections减去superuser_reserved_connections
-	ch <- prometheus.MustNewConstMetric(maxConnDesc, prometheus.GaugeValue, maxConn-reserved)
-	return nil
+
+	errU := scrapeConnLimit(db, roleConnSql, roleConnDesc, nil, roleConnHeadroomDesc, ch)
+	errD := scrapeConnLimit(db, databaseConnSql, databaseConnDesc, databaseConnLimitDesc, databaseConnHeadroomDesc, ch)
+	errS := scrapeSegmentConnections(db, ch)
+
+	return combineErr(errR, errT, errU, errD, errS)
 }
 
 func showConnections(db *sql.DB, sql string) (conn float64, err error) {
 	rows, err := db.Query(sql)
-	logger.Infof("Query Database: %s",sql)
+	logger.Infof("Query Database: %s", sql)
 	if err != nil {
 		return
 	}
@@ -63,3 +133,60 @@ func showConnections(db *sql.DB, sql string) (conn float64, err error) {
 	err = errors.New(fmt.Sprintf("%s not found", sql))
 	return
 }
+
+/**
+* 函数：scrapeConnLimit
+* 功能：执行返回(名称, 连接限制, 当前连接数)的查询，上报连接数、连接限制与剩余连接数；limitDesc为nil时不上报连接限制
+ */
+func scrapeConnLimit(db *sql.DB, query string, connDesc, limitDesc, headroomDesc *prometheus.Desc, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", query)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var name string
+		var limit, conn float64
+		err = rows.Scan(&name, &limit, &conn)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(connDesc, prometheus.GaugeValue, conn, name)
+		if limitDesc != nil {
+			ch <- prometheus.MustNewConstMetric(limitDesc, prometheus.GaugeValue, limit, name)
+		}
+		if limit >= 0 {
+			ch <- prometheus.MustNewConstMetric(headroomDesc, prometheus.GaugeValue, limit-conn, name)
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeSegmentConnections(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", segmentConnSql)
+	rows, err := db.QueryContext(ctx, segmentConnSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var content string
+		var maxConn, backends float64
+		err = rows.Scan(&content, &maxConn, &backends)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(segmentMaxConnDesc, prometheus.GaugeValue, maxConn, content)
+		ch <- prometheus.MustNewConstMetric(segmentBackendsDesc, prometheus.GaugeValue, backends, content)
+	}
+	return combineErr(errs...)
+}
@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ *  孤儿临时schema与segment残留进程抓取器
+ *  GP中临时schema以会话id命名(pg_temp_<sess_id>)，正常退出的会话也会留下空的临时schema，只统计仍包含对象、
+ *  且会话id在master的pg_stat_activity中已不存在的临时schema
+ *  segment上的残留进程通过gp_dist_random('pg_stat_activity')的sess_id识别，包括不持有锁的空闲QE进程
+ */
+
+const (
+	primaryContentsSql = `SELECT content from gp_segment_configuration where role='p';`
+	masterSessionsSql  = `SELECT DISTINCT sess_id from pg_stat_activity;`
+	tempSchemasSql     = `SELECT -1, n.nspname from pg_namespace n
+		where n.nspname ~ '^pg_temp_[0-9]+$' and EXISTS (SELECT 1 from pg_class c where c.relnamespace=n.oid)
+		UNION ALL
+		SELECT n.gp_segment_id, n.nspname from gp_dist_random('pg_namespace') n
+		where n.nspname ~ '^pg_temp_[0-9]+$'
+			and (n.gp_segment_id, n.oid) in (SELECT gp_segment_id, relnamespace from gp_dist_random('pg_class'));`
+	segmentSessionsSql = `SELECT gp_segment_id, sess_id, count(*) from gp_dist_random('pg_stat_activity')
+		where sess_id <> 0 GROUP BY 1,2;`
+)
+
+var (
+	orphanTempSchemasDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "orphan_temp_schemas"),
+		"Number of pg_temp_N schemas of all databases still owning relations whose session no longer exists on master",
+		[]string{"content"}, nil,
+	)
+
+	orphanSegmentBackendsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "orphan_segment_backends"),
+		"Number of segment backends whose session no longer exists on master",
+		[]string{"content"}, nil,
+	)
+)
+
+func NewOrphanScraper() Scraper {
+	return orphanScraper{}
+}
+
+type orphanScraper struct{}
+
+func (orphanScraper) Name() string {
+	return "orphan_scraper"
+}
+
+func (orphanScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	contents, err := queryStrings(db, primaryContentsSql)
+	if err != nil {
+		return err
+	}
+	tempSchemas := make(map[string]float64)
+	backends := make(map[string]float64)
+	for _, content := range contents {
+		tempSchemas[content] = 0
+		if content != "-1" {
+			backends[content] = 0
+		}
+	}
+
+	errs := make([]error, 0)
+	//先读取临时schema与segment进程，再读取master会话，避免把读取期间新建的会话误判为孤儿
+	schemas, err := listTempSchemas(db)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	sessions, err := listSegmentSessions(db)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	masterSessions, err := queryStrings(db, masterSessionsSql)
+	if err != nil {
+		return combineErr(append(errs, err)...)
+	}
+	alive := make(map[string]bool, len(masterSessions))
+	for _, sessId := range masterSessions {
+		alive[sessId] = true
+	}
+
+	for _, s := range schemas {
+		if !alive[s.sessId] {
+			tempSchemas[s.content]++
+		}
+	}
+	for _, s := range sessions {
+		if !alive[s.sessId] {
+			backends[s.content] += s.backends
+		}
+	}
+
+	for content, count := range tempSchemas {
+		ch <- prometheus.MustNewConstMetric(orphanTempSchemasDesc, prometheus.GaugeValue, count, content)
+	}
+	for content, count := range backends {
+		ch <- prometheus.MustNewConstMetric(orphanSegmentBackendsDesc, prometheus.GaugeValue, count, content)
+	}
+	return combineErr(errs...)
+}
+
+type sessionRef struct {
+	content  string
+	sessId   string
+	backends float64
+}
+
+/**
+* 函数：listTempSchemas
+* 功能：遍历postgres库与所有用户数据库，获取master与各segment上的临时schema及其会话id
+ */
+func listTempSchemas(db *sql.DB) ([]sessionRef, error) {
+	names, err := listDatabases(db)
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]error, 0)
+	schemas, err := queryTempSchemas(db)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("database postgres: %v", err))
+	}
+	for _, dbname := range names {
+		conn, err := getDatabaseConn(dbname)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+			continue
+		}
+		dbSchemas, err := queryTempSchemas(conn)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+			continue
+		}
+		schemas = append(schemas, dbSchemas...)
+	}
+	return schemas, combineErr(errs...)
+}
+
+func queryTempSchemas(conn *sql.DB) ([]sessionRef, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", tempSchemasSql)
+	rows, err := conn.QueryContext(ctx, tempSchemasSql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	schemas := make([]sessionRef, 0)
+	for rows.Next() {
+		var content, nspname string
+		if err = rows.Scan(&content, &nspname); err != nil {
+			return nil, err
+		}
+		sessId := strings.TrimPrefix(nspname, "pg_temp_")
+		if _, err := strconv.Atoi(sessId); err != nil {
+			continue
+		}
+		schemas = append(schemas, sessionRef{content: content, sessId: sessId})
+	}
+	return schemas, rows.Err()
+}
+
+func listSegmentSessions(db *sql.DB) ([]sessionRef, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", segmentSessionsSql)
+	rows, err := db.QueryContext(ctx, segmentSessionsSql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := make([]sessionRef, 0)
+	for rows.Next() {
+		var s sessionRef
+		if err = rows.Scan(&s.content, &s.sessId, &s.backends); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+/**
+* 函数：queryStrings
+* 功能：执行返回单列的查询，以字符串切片返回结果
+ */
+func queryStrings(db *sql.DB, query string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", query)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,230 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ *  分区表抓取器
+ *  pg_partitions视图在5.x与6.x中均可用（pg_partition_tree要到7.x才提供），按数据库逐个统计：
+ *  每个父表的分区数量、范围分区最早/最晚的边界、含有数据的默认分区数量
+ */
+
+const (
+	partitionCountSql = `SELECT schemaname, tablename, count(*) as partitions from pg_partitions GROUP BY 1,2;`
+	//只取第一级范围分区中最早（rank=1）与最晚（rank最大）的两个分区
+	partitionBoundarySql = `SELECT p.schemaname, p.tablename, p.partitionrank, m.max_rank, p.partitionrangestart, p.partitionrangeend
+		FROM pg_partitions p
+		JOIN (SELECT schemaname, tablename, max(partitionrank) as max_rank from pg_partitions
+			WHERE partitiontype='range' and partitionlevel=0 GROUP BY 1,2) m
+		ON p.schemaname=m.schemaname and p.tablename=m.tablename
+		WHERE p.partitiontype='range' and p.partitionlevel=0 and (p.partitionrank=1 or p.partitionrank=m.max_rank);`
+	defaultPartitionSql    = `SELECT partitionschemaname, partitiontablename from pg_partitions where partitionisdefault;`
+	defaultPartitionRowSql = `SELECT count(*) from (SELECT 1 from %s.%s limit 1) t;`
+)
+
+var (
+	partitionCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "partition_total_count"),
+		"Total partition count of each partitioned table, including sub partitions and default partitions",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+
+	partitionOldestStartDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "partition_range_oldest_start"),
+		"Start boundary of the oldest range partition, unix timestamp for date/time keys or the numeric value itself",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+
+	partitionNewestEndDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "partition_range_newest_end"),
+		"End boundary of the newest range partition, unix timestamp for date/time keys or the numeric value itself",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+
+	defaultPartitionWithRowsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "partition_default_with_rows_count"),
+		"Number of default partitions holding rows of each database",
+		[]string{"dbname"},
+		nil,
+	)
+)
+
+// 分区边界表达式，如：'2020-01-01'::date、'2020-01-01 00:00:00'::timestamp without time zone、100
+var partitionBoundaryRegex = regexp.MustCompile(`^'(.*)'::(.+)$`)
+
+var partitionTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func NewPartitionScraper() Scraper {
+	return partitionScraper{}
+}
+
+type partitionScraper struct{}
+
+func (partitionScraper) Name() string {
+	return "partition_scraper"
+}
+
+func (partitionScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	names, err := listDatabases(db)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, dbname := range names {
+		if err := scrapeDatabasePartitions(dbname, ch); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeDatabasePartitions(dbname string, ch chan<- prometheus.Metric) error {
+	conn, err := getDatabaseConn(dbname)
+	if err != nil {
+		return err
+	}
+
+	errC := scrapePartitionCount(conn, dbname, ch)
+	errB := scrapePartitionBoundary(conn, dbname, ch)
+	errD := scrapeDefaultPartitionRows(conn, dbname, ch)
+
+	return combineErr(errC, errB, errD)
+}
+
+func scrapePartitionCount(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", partitionCountSql)
+	rows, err := conn.QueryContext(ctx, partitionCountSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var schema, table string
+		var count float64
+		err = rows.Scan(&schema, &table, &count)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(partitionCountDesc, prometheus.GaugeValue, count, dbname, schema, table)
+	}
+	return combineErr(errs...)
+}
+
+func scrapePartitionBoundary(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", partitionBoundarySql)
+	rows, err := conn.QueryContext(ctx, partitionBoundarySql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var schema, table string
+		var rank, maxRank int64
+		var rangeStart, rangeEnd sql.NullString
+		err = rows.Scan(&schema, &table, &rank, &maxRank, &rangeStart, &rangeEnd)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if rank == 1 {
+			if start, ok := parsePartitionBoundary(rangeStart.String); ok {
+				ch <- prometheus.MustNewConstMetric(partitionOldestStartDesc, prometheus.GaugeValue, start, dbname, schema, table)
+			}
+		}
+		if rank == maxRank {
+			if end, ok := parsePartitionBoundary(rangeEnd.String); ok {
+				ch <- prometheus.MustNewConstMetric(partitionNewestEndDesc, prometheus.GaugeValue, end, dbname, schema, table)
+			}
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeDefaultPartitionRows(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", defaultPartitionSql)
+	rows, err := conn.QueryContext(ctx, defaultPartitionSql)
+	if err != nil {
+		return err
+	}
+	tables := make([]string, 0)
+	for rows.Next() {
+		var schema, table string
+		if err = rows.Scan(&schema, &table); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, fmt.Sprintf(defaultPartitionRowSql, pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)))
+	}
+	rows.Close()
+
+	errs := make([]error, 0)
+	var withRows float64
+	for _, query := range tables {
+		var count float64
+		logger.Infof("Query Database: %s", query)
+		rowCtx, rowCancel := context.WithTimeout(context.Background(), time.Second*10)
+		err = conn.QueryRowContext(rowCtx, query).Scan(&count)
+		rowCancel()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if count > 0 {
+			withRows++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(defaultPartitionWithRowsDesc, prometheus.GaugeValue, withRows, dbname)
+	return combineErr(errs...)
+}
+
+/**
+* 函数：parsePartitionBoundary
+* 功能：将分区边界表达式转换为数值，日期时间类型转换为unix时间戳，无法识别的返回false
+ */
+func parsePartitionBoundary(expr string) (float64, bool) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return 0, false
+	}
+	if match := partitionBoundaryRegex.FindStringSubmatch(expr); match != nil {
+		expr = match[1]
+		if strings.HasPrefix(match[2], "date") || strings.HasPrefix(match[2], "timestamp") {
+			for _, layout := range partitionTimeLayouts {
+				if t, err := time.Parse(layout, expr); err == nil {
+					return float64(t.Unix()), true
+				}
+			}
+			return 0, false
+		}
+	}
+	if v, err := strconv.ParseFloat(expr, 64); err == nil {
+		return v, true
+	}
+	return 0, false
+}
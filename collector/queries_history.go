@@ -0,0 +1,279 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"sync"
+	"time"
+)
+
+/**
+ *  gpperfmon查询明细抓取器，只对Pivotal Greenplum 5/6有效
+ *  queries_now按用户/数据库/资源组汇总当前查询；queries_history按ctime水位线增量读取，每条历史记录只累计一次
+ *  gpperfmon写入历史记录有延迟，ctime只精确到秒，每次从水位线之前一段重叠窗口开始读取，按(tmid, ssid, ccnt)去重
+ *  资源组标签取自rsqname字段（5.x中为资源队列）
+ */
+
+const (
+	//gpperfmon function
+	queriesNowSql = `SELECT username, db, coalesce(rsqname, ''), status, count(*),
+		coalesce(sum(cpu_currpct), 0), coalesce(max(skew_cpu), 0), coalesce(sum(rows_out), 0)
+		from queries_now GROUP BY 1,2,3,4;`
+	queriesHistoryWatermarkSql = `SELECT coalesce(max(ctime), now()::timestamp) from queries_history;`
+	//水位线过旧时最多只读取最近1天的记录，避免全表扫描
+	queriesHistoryLimitSql = `SELECT (now() - interval '1 day')::timestamp;`
+	queriesHistorySkipSql  = `SELECT count(*) from queries_history where ctime >= $1 and ctime < $2;`
+	queriesHistorySql      = `SELECT tmid, ssid, ccnt, ctime, username, db, coalesce(rsqname, ''), status,
+		coalesce(extract(epoch from (tfinish - tstart)), 0), coalesce(cpu_elapsed, 0), coalesce(rows_out, 0)
+		from queries_history where ctime >= $1 order by ctime;`
+)
+
+// 读取queries_history时水位线之前的重叠窗口，覆盖gpperfmon写入历史记录的延迟
+const queriesHistoryOverlap = time.Minute * 5
+
+// 查询耗时直方图的桶（秒）
+var queryDurationBuckets = []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600, 7200}
+
+var (
+	queriesNowCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_now_count"),
+		"Number of queries in queries_now by status",
+		[]string{"username", "db", "resource_group", "status"}, nil,
+	)
+
+	queriesNowCpuDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_now_cpu_percent"),
+		"Sum of current CPU percent of queries in queries_now",
+		[]string{"username", "db", "resource_group", "status"}, nil,
+	)
+
+	queriesNowSkewCpuDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_now_skew_cpu_max"),
+		"Max CPU skew of queries in queries_now",
+		[]string{"username", "db", "resource_group", "status"}, nil,
+	)
+
+	queriesNowRowsOutDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_now_rows_out"),
+		"Sum of rows out of queries in queries_now",
+		[]string{"username", "db", "resource_group", "status"}, nil,
+	)
+
+	queriesHistoryCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_history_total"),
+		"Number of finished queries read from queries_history by status",
+		[]string{"username", "db", "resource_group", "status"}, nil,
+	)
+
+	queriesHistoryDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_history_duration_seconds"),
+		"Duration of finished queries read from queries_history",
+		[]string{"username", "db", "resource_group"}, nil,
+	)
+
+	queriesHistoryCpuDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_history_cpu_elapsed_seconds_total"),
+		"CPU seconds used by finished queries across all segments",
+		[]string{"username", "db", "resource_group"}, nil,
+	)
+
+	queriesHistoryRowsOutDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "queries_history_rows_out_total"),
+		"Rows out of finished queries",
+		[]string{"username", "db", "resource_group"}, nil,
+	)
+)
+
+type queryHistoryKey struct {
+	username, db, resourceGroup string
+}
+
+// queries_history中一条查询记录的标识
+type queryHistoryID struct {
+	tmid, ssid, ccnt int64
+}
+
+type queryHistoryStatusKey struct {
+	queryHistoryKey
+	status string
+}
+
+// 按用户/数据库/资源组累计的历史查询统计
+type queryHistoryStats struct {
+	buckets       map[float64]uint64
+	durationSum   float64
+	durationCount uint64
+	cpuElapsed    float64
+	rowsOut       float64
+}
+
+func NewQueriesHistoryScraper() Scraper {
+	return &queriesHistoryScraper{
+		seen:   make(map[queryHistoryID]time.Time),
+		counts: make(map[queryHistoryStatusKey]float64),
+		stats:  make(map[queryHistoryKey]*queryHistoryStats),
+	}
+}
+
+type queriesHistoryScraper struct {
+	mu        sync.Mutex
+	watermark time.Time
+	//重叠窗口内已累计的记录及其ctime
+	seen   map[queryHistoryID]time.Time
+	counts map[queryHistoryStatusKey]float64
+	stats  map[queryHistoryKey]*queryHistoryStats
+}
+
+func (*queriesHistoryScraper) Name() string {
+	return "queries_history_scraper"
+}
+
+func (s *queriesHistoryScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	errN := scrapeQueriesNow(db, ch)
+	errH := s.scrapeQueriesHistory(db, ch)
+	return combineErr(errN, errH)
+}
+
+func scrapeQueriesNow(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", queriesNowSql)
+	rows, err := db.QueryContext(ctx, queriesNowSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var username, dbname, resourceGroup, status string
+		var count, cpu, skewCpu, rowsOut float64
+		err = rows.Scan(&username, &dbname, &resourceGroup, &status, &count, &cpu, &skewCpu, &rowsOut)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(queriesNowCountDesc, prometheus.GaugeValue, count, username, dbname, resourceGroup, status)
+		ch <- prometheus.MustNewConstMetric(queriesNowCpuDesc, prometheus.GaugeValue, cpu, username, dbname, resourceGroup, status)
+		ch <- prometheus.MustNewConstMetric(queriesNowSkewCpuDesc, prometheus.GaugeValue, skewCpu, username, dbname, resourceGroup, status)
+		ch <- prometheus.MustNewConstMetric(queriesNowRowsOutDesc, prometheus.GaugeValue, rowsOut, username, dbname, resourceGroup, status)
+	}
+	return combineErr(errs...)
+}
+
+func (s *queriesHistoryScraper) scrapeQueriesHistory(db *sql.DB, ch chan<- prometheus.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.readQueriesHistory(db)
+	s.collect(ch)
+	return err
+}
+
+/**
+* 函数：readQueriesHistory
+* 功能：读取水位线减去重叠窗口之后的queries_history记录，跳过已累计的记录；首次抓取只初始化水位线，不累计已有的历史记录
+ */
+func (s *queriesHistoryScraper) readQueriesHistory(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if s.watermark.IsZero() {
+		logger.Infof("Query Database: %s", queriesHistoryWatermarkSql)
+		return db.QueryRowContext(ctx, queriesHistoryWatermarkSql).Scan(&s.watermark)
+	}
+
+	from, err := s.historyLowerBound(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Query Database: %s", queriesHistorySql)
+	rows, err := db.QueryContext(ctx, queriesHistorySql, from)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var id queryHistoryID
+		var ctime time.Time
+		var username, dbname, resourceGroup, status string
+		var duration, cpuElapsed, rowsOut float64
+		err = rows.Scan(&id.tmid, &id.ssid, &id.ccnt, &ctime, &username, &dbname, &resourceGroup, &status, &duration, &cpuElapsed, &rowsOut)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := s.seen[id]; ok {
+			continue
+		}
+		s.seen[id] = ctime
+		key := queryHistoryKey{username: username, db: dbname, resourceGroup: resourceGroup}
+		s.counts[queryHistoryStatusKey{queryHistoryKey: key, status: status}]++
+		stats, ok := s.stats[key]
+		if !ok {
+			stats = &queryHistoryStats{buckets: make(map[float64]uint64, len(queryDurationBuckets))}
+			for _, bound := range queryDurationBuckets {
+				stats.buckets[bound] = 0
+			}
+			s.stats[key] = stats
+		}
+		for _, bound := range queryDurationBuckets {
+			if duration <= bound {
+				stats.buckets[bound]++
+			}
+		}
+		stats.durationSum += duration
+		stats.durationCount++
+		stats.cpuElapsed += cpuElapsed
+		stats.rowsOut += rowsOut
+		if ctime.After(s.watermark) {
+			s.watermark = ctime
+		}
+	}
+	for id, ctime := range s.seen {
+		if ctime.Before(s.watermark.Add(-queriesHistoryOverlap)) {
+			delete(s.seen, id)
+		}
+	}
+	return combineErr(errs...)
+}
+
+/**
+* 函数：historyLowerBound
+* 功能：返回本次读取queries_history的起始时间，即水位线减去重叠窗口，但不早于1天前；
+*      早于1天前的记录被跳过时在日志中记录跳过的记录数
+ */
+func (s *queriesHistoryScraper) historyLowerBound(ctx context.Context, db *sql.DB) (time.Time, error) {
+	from := s.watermark.Add(-queriesHistoryOverlap)
+	logger.Infof("Query Database: %s", queriesHistoryLimitSql)
+	var limit time.Time
+	if err := db.QueryRowContext(ctx, queriesHistoryLimitSql).Scan(&limit); err != nil {
+		return from, err
+	}
+	if !from.Before(limit) {
+		return from, nil
+	}
+	logger.Infof("Query Database: %s", queriesHistorySkipSql)
+	var skipped int64
+	if err := db.QueryRowContext(ctx, queriesHistorySkipSql, from, limit).Scan(&skipped); err != nil {
+		return limit, err
+	}
+	if skipped > 0 {
+		logger.Warnf("queries_history watermark %v is older than 1 day, %d rows before %v are skipped", s.watermark, skipped, limit)
+	}
+	return limit, nil
+}
+
+func (s *queriesHistoryScraper) collect(ch chan<- prometheus.Metric) {
+	for key, count := range s.counts {
+		ch <- prometheus.MustNewConstMetric(queriesHistoryCountDesc, prometheus.CounterValue, count, key.username, key.db, key.resourceGroup, key.status)
+	}
+	for key, stats := range s.stats {
+		ch <- prometheus.MustNewConstHistogram(queriesHistoryDurationDesc, stats.durationCount, stats.durationSum, stats.buckets, key.username, key.db, key.resourceGroup)
+		ch <- prometheus.MustNewConstMetric(queriesHistoryCpuDesc, prometheus.CounterValue, stats.cpuElapsed, key.username, key.db, key.resourceGroup)
+		ch <- prometheus.MustNewConstMetric(queriesHistoryRowsOutDesc, prometheus.CounterValue, stats.rowsOut, key.username, key.db, key.resourceGroup)
+	}
+}
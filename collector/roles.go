@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"strconv"
+	"time"
+)
+
+/**
+ *  角色与权限信息抓取器
+ */
+
+const (
+	rolesSql = `SELECT r.rolname, r.rolsuper, r.rolcanlogin, r.rolcreatedb, r.rolconnlimit,
+		coalesce(q.rsqname, ''), coalesce(g.rsgname, ''),
+		case when r.rolvaliduntil is null or r.rolvaliduntil = 'infinity' then null else extract(epoch from r.rolvaliduntil) end,
+		case when r.rolvaliduntil is null or r.rolvaliduntil = 'infinity' then null else extract(epoch from r.rolvaliduntil - now())/86400 end
+		from pg_roles r
+		LEFT JOIN pg_resqueue q ON q.oid=r.rolresqueue
+		LEFT JOIN pg_resgroup g ON g.oid=r.rolresgroup;`
+)
+
+var (
+	roleInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "role_info"),
+		"Attributes of each role, always 1",
+		[]string{"rolname", "superuser", "login", "createdb", "resource_queue", "resource_group"}, nil,
+	)
+
+	roleConnLimitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "role_connection_limit"),
+		"Connection limit of each role, -1 means no limit",
+		[]string{"rolname"}, nil,
+	)
+
+	roleValidUntilDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "role_valid_until_timestamp"),
+		"Password expiry time of each role with an expiry set",
+		[]string{"rolname"}, nil,
+	)
+
+	roleDaysUntilExpiryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "role_days_until_expiry"),
+		"Days until the password of each login role expires, negative if already expired",
+		[]string{"rolname"}, nil,
+	)
+
+	rolesNoExpiryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "login_roles_without_expiry_count"),
+		"Number of login roles without a password expiry",
+		nil, nil,
+	)
+)
+
+func NewRolesScraper() Scraper {
+	return rolesScraper{}
+}
+
+type rolesScraper struct{}
+
+func (rolesScraper) Name() string {
+	return "roles_scraper"
+}
+
+func (rolesScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", rolesSql)
+	rows, err := db.QueryContext(ctx, rolesSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	var noExpiry float64
+	for rows.Next() {
+		var rolname, resQueue, resGroup string
+		var superuser, login, createdb bool
+		var connLimit float64
+		var validUntil, daysUntilExpiry sql.NullFloat64
+		err = rows.Scan(&rolname, &superuser, &login, &createdb, &connLimit, &resQueue, &resGroup, &validUntil, &daysUntilExpiry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(roleInfoDesc, prometheus.GaugeValue, 1, rolname,
+			strconv.FormatBool(superuser), strconv.FormatBool(login), strconv.FormatBool(createdb), resQueue, resGroup)
+		ch <- prometheus.MustNewConstMetric(roleConnLimitDesc, prometheus.GaugeValue, connLimit, rolname)
+		if validUntil.Valid {
+			ch <- prometheus.MustNewConstMetric(roleValidUntilDesc, prometheus.GaugeValue, validUntil.Float64, rolname)
+		}
+		if !login {
+			continue
+		}
+		if daysUntilExpiry.Valid {
+			ch <- prometheus.MustNewConstMetric(roleDaysUntilExpiryDesc, prometheus.GaugeValue, daysUntilExpiry.Float64, rolname)
+		} else {
+			noExpiry++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(rolesNoExpiryDesc, prometheus.GaugeValue, noExpiry)
+	return combineErr(errs...)
+}
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"time"
+)
+
+/**
+ *  各个数据库内schema及大表存储大小抓取器
+ *  schema过滤使用PostgreSQL正则表达式(~)，exclude为空时不做排除
+ */
+
+const (
+	schemaSizeSql = `SELECT sosdnsp, sosdschematablesize/(1024*1024), sosdschemaidxsize/(1024*1024)
+		from gp_toolkit.gp_size_of_schema_disk
+		where sosdnsp ~ $1 and ($2 = '' or sosdnsp !~ $2);`
+	topTableSizeSql = `SELECT sotaidschemaname, sotaidtablename, sotaidtablesize/(1024*1024), sotaididxsize/(1024*1024)
+		from gp_toolkit.gp_size_of_table_and_indexes_disk
+		where sotaidschemaname ~ $1 and ($2 = '' or sotaidschemaname !~ $2)
+		order by sotaidtablesize+sotaididxsize desc limit $3;`
+)
+
+var (
+	sizeTopN = kingpin.Flag("collect.size.top-n",
+		"number of largest tables (including indexes) reported per database").Default("10").Int()
+	sizeSchemaInclude = kingpin.Flag("collect.size.schema-include",
+		"regex of schemas included in schema and table size metrics").Default(".*").String()
+	sizeSchemaExclude = kingpin.Flag("collect.size.schema-exclude",
+		"regex of schemas excluded from schema and table size metrics").Default("^(pg_.*|gp_toolkit|information_schema)$").String()
+)
+
+var (
+	schemaTableSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "schema_table_mb_size"),
+		"Total MB size of tables in each schema in the file system",
+		[]string{"dbname", "schema"},
+		nil,
+	)
+
+	schemaIndexSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "schema_index_mb_size"),
+		"Total MB size of indexes in each schema in the file system",
+		[]string{"dbname", "schema"},
+		nil,
+	)
+
+	topTableSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "table_mb_size"),
+		"MB size of each of the top-N largest tables in the file system",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+
+	topTableIndexSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "table_index_mb_size"),
+		"MB size of indexes of each of the top-N largest tables in the file system",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+)
+
+func NewSchemaSizeScraper() Scraper {
+	return schemaSizeScraper{}
+}
+
+type schemaSizeScraper struct{}
+
+func (schemaSizeScraper) Name() string {
+	return "schema_size_scraper"
+}
+
+func (schemaSizeScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	names, err := listDatabases(db)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, dbname := range names {
+		if err := scrapeDatabaseSchemaSize(dbname, ch); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeDatabaseSchemaSize(dbname string, ch chan<- prometheus.Metric) error {
+	conn, err := getDatabaseConn(dbname)
+	if err != nil {
+		return err
+	}
+
+	errS := scrapeSchemaSize(conn, dbname, ch)
+	errT := scrapeTopTableSize(conn, dbname, ch)
+
+	return combineErr(errS, errT)
+}
+
+func scrapeSchemaSize(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", schemaSizeSql)
+	rows, err := conn.QueryContext(ctx, schemaSizeSql, *sizeSchemaInclude, *sizeSchemaExclude)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var schema string
+		var tableSize, indexSize float64
+		err = rows.Scan(&schema, &tableSize, &indexSize)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(schemaTableSizeDesc, prometheus.GaugeValue, tableSize, dbname, schema)
+		ch <- prometheus.MustNewConstMetric(schemaIndexSizeDesc, prometheus.GaugeValue, indexSize, dbname, schema)
+	}
+	return combineErr(errs...)
+}
+
+func scrapeTopTableSize(conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+	if *sizeTopN <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", topTableSizeSql)
+	rows, err := conn.QueryContext(ctx, topTableSizeSql, *sizeSchemaInclude, *sizeSchemaExclude, *sizeTopN)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var schema, table string
+		var tableSize, indexSize float64
+		err = rows.Scan(&schema, &table, &tableSize, &indexSize)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(topTableSizeDesc, prometheus.GaugeValue, tableSize, dbname, schema, table)
+		ch <- prometheus.MustNewConstMetric(topTableIndexSizeDesc, prometheus.GaugeValue, indexSize, dbname, schema, table)
+	}
+	return combineErr(errs...)
+}
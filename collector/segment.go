@@ -40,6 +40,24 @@ var (
 		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir"}, nil,
 	)
 
+	statusStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_status_state"),
+		"The segment's status as a state set, 1 for the current status and 0 for the others",
+		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir", "status"}, nil,
+	)
+
+	roleStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_role_state"),
+		"The segment's current role as a state set, 1 for the current role and 0 for the others",
+		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir", "role"}, nil,
+	)
+
+	modeStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_mode_state"),
+		"The replication mode for the segment as a state set, 1 for the current mode and 0 for the others",
+		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir", "mode"}, nil,
+	)
+
 	segmentDiskFreeSizeDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_free_mb_size"), //指标的名称
 		"Total MB size of each segment node free size of disk in the file system",     //帮助信息，显示在指标的上面作为注释
@@ -81,17 +99,26 @@ func (segmentScraper5) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
 }
 
 func scrapeSegmentConfig6(db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeSegmentConfig(db, segmentConfigSql6, ch)
+}
+
+func scrapeSegmentConfig5(db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeSegmentConfig(db, segmentConfigSql5, ch)
+}
+
+func scrapeSegmentConfig(db *sql.DB, query string, ch chan<- prometheus.Metric) error {
 	ctx := context.Background()
 	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
-	logger.Infof("Query Database: %s", segmentConfigSql6)
-	rows, err := db.QueryContext(ctx, segmentConfigSql6)
+	logger.Infof("Query Database: %s", query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 	errs := make([]error, 0)
+	segments := make([]segmentInfo, 0)
 	for rows.Next() {
 		var dbID, content, role, preferredRole, mode, status, hostname, address, port string
 		var rp sql.NullString
@@ -104,38 +131,35 @@ func scrapeSegmentConfig6(db *sql.DB, ch chan<- prometheus.Metric) error {
 		ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, getStatus(status), hostname, address, dbID, content, preferredRole, port, rp.String)
 		ch <- prometheus.MustNewConstMetric(roleDesc, prometheus.GaugeValue, getRole(role), hostname, address, dbID, content, preferredRole, port, rp.String)
 		ch <- prometheus.MustNewConstMetric(modeDesc, prometheus.GaugeValue, getMode(mode), hostname, address, dbID, content, preferredRole, port, rp.String)
+
+		scrapeSegmentStateSet(statusStateDesc, sgStatusStates, status, ch, hostname, address, dbID, content, preferredRole, port, rp.String)
+		scrapeSegmentStateSet(roleStateDesc, sgRoleStates, role, ch, hostname, address, dbID, content, preferredRole, port, rp.String)
+		scrapeSegmentStateSet(modeStateDesc, sgModeStates, mode, ch, hostname, address, dbID, content, preferredRole, port, rp.String)
+
+		segments = append(segments, segmentInfo{content: content, role: role, preferredRole: preferredRole, mode: mode, status: status, hostname: hostname})
 	}
+	scrapeSegmentBalance(segments, ch)
+	scrapeSegmentHostRollup(segments, ch)
 
 	return combineErr(errs...)
 }
 
-func scrapeSegmentConfig5(db *sql.DB, ch chan<- prometheus.Metric) error {
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
-	defer cancel()
-
-	logger.Infof("Query Database: %s", segmentConfigSql5)
-	rows, err := db.QueryContext(ctx, segmentConfigSql5)
-	if err != nil {
-		return err
+/**
+* 函数：scrapeSegmentStateSet
+* 功能：按StateSet形式上报，每个可能的状态一条时间序列，当前状态为1，其余为0
+ */
+func scrapeSegmentStateSet(desc *prometheus.Desc, states map[string]string, value string, ch chan<- prometheus.Metric, labels ...string) {
+	current := getState(states, value)
+	if current == sgUnknownState {
+		logger.Warnf("unknown segment state value: %q", value)
 	}
-	defer rows.Close()
-	errs := make([]error, 0)
-	for rows.Next() {
-		var dbID, content, role, preferredRole, mode, status, hostname, address, port string
-		var rp sql.NullString
-		err = rows.Scan(&dbID, &content, &role, &preferredRole, &mode, &status, &port, &hostname, &address, &rp)
-		if err != nil {
-			errs = append(errs, err)
-			continue
+	for _, name := range stateSetNames(states) {
+		var v float64
+		if name == current {
+			v = 1
 		}
-
-		ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, getStatus(status), hostname, address, dbID, content, preferredRole, port, rp.String)
-		ch <- prometheus.MustNewConstMetric(roleDesc, prometheus.GaugeValue, getRole(role), hostname, address, dbID, content, preferredRole, port, rp.String)
-		ch <- prometheus.MustNewConstMetric(modeDesc, prometheus.GaugeValue, getMode(mode), hostname, address, dbID, content, preferredRole, port, rp.String)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, append(labels, name)...)
 	}
-
-	return combineErr(errs...)
 }
 
 func scrapeSegmentDiskFree(db *sql.DB, ch chan<- prometheus.Metric) error {
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"math"
+	"strings"
+)
+
+/**
+ * 集群均衡状态的派生指标，基于同一次gp_segment_configuration查询计算
+ * 出现角色未回归preferred_role、主机primary数量超出平均值或mirror宕机时，需要执行gprecoverseg -r或gprecoverseg
+ */
+
+var (
+	hostNotPreferredRoleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_not_preferred_role_count"),
+		"Number of segments on this host whose role differs from preferred_role",
+		[]string{"hostname"}, nil,
+	)
+
+	hostPrimaryCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_primary_count"),
+		"Number of primary segments currently running on this host",
+		[]string{"hostname"}, nil,
+	)
+
+	hostsOverFairShareDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "hosts_over_primary_fair_share"),
+		"Number of hosts running more primary segments than their fair share (primaries divided by hosts, rounded up)",
+		nil, nil,
+	)
+
+	contentsMirrorDownDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "contents_mirror_down"),
+		"Number of content ids whose mirror segment is down",
+		nil, nil,
+	)
+)
+
+/**
+* 函数：scrapeSegmentBalance
+* 功能：根据segment列表计算集群均衡状态的派生指标
+ */
+func scrapeSegmentBalance(segments []segmentInfo, ch chan<- prometheus.Metric) {
+	notPreferred := make(map[string]float64)
+	primaries := make(map[string]float64)
+	mirrorDown := make(map[string]bool)
+	var totalPrimaries float64
+	for _, s := range segments {
+		if s.isMaster() {
+			continue
+		}
+		if _, ok := notPreferred[s.hostname]; !ok {
+			notPreferred[s.hostname] = 0
+			primaries[s.hostname] = 0
+		}
+		if !strings.EqualFold(s.role, s.preferredRole) {
+			notPreferred[s.hostname]++
+		}
+		if getRole(s.role) == sgRole["p"] {
+			primaries[s.hostname]++
+			totalPrimaries++
+		} else if getStatus(s.status) == sgStatus["d"] {
+			mirrorDown[s.content] = true
+		}
+	}
+
+	var overFairShare float64
+	if len(primaries) > 0 {
+		fairShare := math.Ceil(totalPrimaries / float64(len(primaries)))
+		for hostname, count := range primaries {
+			if count > fairShare {
+				overFairShare++
+			}
+			ch <- prometheus.MustNewConstMetric(hostPrimaryCountDesc, prometheus.GaugeValue, count, hostname)
+			ch <- prometheus.MustNewConstMetric(hostNotPreferredRoleDesc, prometheus.GaugeValue, notPreferred[hostname], hostname)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(hostsOverFairShareDesc, prometheus.GaugeValue, overFairShare)
+	ch <- prometheus.MustNewConstMetric(contentsMirrorDownDesc, prometheus.GaugeValue, float64(len(mirrorDown)))
+}
@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"time"
+)
+
+/**
+ *  segment数据目录磁盘抓取器，开源版与Pivotal版均有效
+ *  按dbid/content/设备上报gp_toolkit.gp_disk_free的剩余空间与segment数据目录中数据库的大小
+ *  gp_disk_free没有设备总大小，也不包含mirror，占用百分比只按同一主机同一设备上所有primary的数据大小与剩余空间计算，
+ *  不包含同一设备上的mirror数据与非Greenplum文件，设备上有mirror时低于实际使用率
+ *  预计写满天数按设备上所有primary数据大小在collect.history.window内线性回归得到的增长速度计算，同样不包含mirror的增长
+ */
+
+const (
+	segmentDiskSql = `SELECT c.dbid, c.content, f.dfhostname, f.dfdevice, f.dfspace*1024
+		from gp_toolkit.gp_disk_free f JOIN gp_segment_configuration c ON c.content=f.dfsegment and c.role='p';`
+	segmentDataSizeSql = `SELECT gp_segment_id, sum(pg_database_size(datname)) from gp_dist_random('pg_database') GROUP BY 1;`
+)
+
+var (
+	segmentDiskFreeBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_free_bytes"),
+		"Free bytes of the device of each primary segment data directory",
+		[]string{"hostname", "dbid", "content", "device"}, nil,
+	)
+
+	segmentDataBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_data_bytes"),
+		"Total size of all databases in each primary segment data directory",
+		[]string{"hostname", "dbid", "content", "device"}, nil,
+	)
+
+	segmentDiskPrimaryUsedPercentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_primary_used_percent"),
+		"Percent of primary segment data on the device against primary segment data plus free space, excluding mirror data and non-Greenplum files",
+		[]string{"hostname", "dbid", "content", "device"}, nil,
+	)
+
+	segmentDiskDaysUntilFullDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_days_until_full"),
+		"Days until the device is full at the data growth rate of all primary segments on the device over collect.history.window, only reported when growing",
+		[]string{"hostname", "dbid", "content", "device"}, nil,
+	)
+)
+
+type segmentDisk struct {
+	dbid, content, hostname, device string
+	free, data                      float64
+}
+
+type deviceKey struct {
+	hostname, device string
+}
+
+func NewSegmentDiskScraper() Scraper {
+	return segmentDiskScraper{}
+}
+
+type segmentDiskScraper struct{}
+
+func (segmentDiskScraper) Name() string {
+	return "segment_disk_scraper"
+}
+
+func (segmentDiskScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	disks, err := listSegmentDisks(db)
+	if err != nil {
+		return err
+	}
+	dataSize, err := querySegmentDataSize(db)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deviceData := make(map[deviceKey]float64)
+	deviceGrowth := make(map[deviceKey]float64)
+	for i := range disks {
+		d := &disks[i]
+		d.data = dataSize[d.content]
+		key := deviceKey{hostname: d.hostname, device: d.device}
+		deviceData[key] += d.data
+		if rate, ok := sizeHistories.record("segment/"+d.dbid, now, d.data); ok {
+			deviceGrowth[key] += rate
+		}
+	}
+
+	for _, d := range disks {
+		labels := []string{d.hostname, d.dbid, d.content, d.device}
+		ch <- prometheus.MustNewConstMetric(segmentDiskFreeBytesDesc, prometheus.GaugeValue, d.free, labels...)
+		ch <- prometheus.MustNewConstMetric(segmentDataBytesDesc, prometheus.GaugeValue, d.data, labels...)
+		key := deviceKey{hostname: d.hostname, device: d.device}
+		if total := deviceData[key] + d.free; total > 0 {
+			ch <- prometheus.MustNewConstMetric(segmentDiskPrimaryUsedPercentDesc, prometheus.GaugeValue, deviceData[key]/total*100, labels...)
+		}
+		if rate := deviceGrowth[key]; rate > 0 {
+			ch <- prometheus.MustNewConstMetric(segmentDiskDaysUntilFullDesc, prometheus.GaugeValue, d.free/rate/86400, labels...)
+		}
+	}
+	return sizeHistories.flush()
+}
+
+func listSegmentDisks(db *sql.DB) ([]segmentDisk, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", segmentDiskSql)
+	rows, err := db.QueryContext(ctx, segmentDiskSql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	disks := make([]segmentDisk, 0)
+	for rows.Next() {
+		var d segmentDisk
+		if err = rows.Scan(&d.dbid, &d.content, &d.hostname, &d.device, &d.free); err != nil {
+			return nil, err
+		}
+		disks = append(disks, d)
+	}
+	return disks, rows.Err()
+}
+
+func querySegmentDataSize(db *sql.DB) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", segmentDataSizeSql)
+	rows, err := db.QueryContext(ctx, segmentDataSizeSql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sizes := make(map[string]float64)
+	for rows.Next() {
+		var content string
+		var size float64
+		if err = rows.Scan(&content, &size); err != nil {
+			return nil, err
+		}
+		sizes[content] = size
+	}
+	return sizes, rows.Err()
+}
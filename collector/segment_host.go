@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ * 按主机汇总的segment状态指标，基于同一次gp_segment_configuration查询计算
+ */
+
+var (
+	hostPrimaryUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_host_primary_up"),
+		"Number of primary segments that are up on this host",
+		[]string{"hostname"}, nil,
+	)
+
+	hostPrimaryDownDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_host_primary_down"),
+		"Number of primary segments that are down on this host",
+		[]string{"hostname"}, nil,
+	)
+
+	hostMirrorUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_host_mirror_up"),
+		"Number of mirror segments that are up on this host",
+		[]string{"hostname"}, nil,
+	)
+
+	hostMirrorDownDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_host_mirror_down"),
+		"Number of mirror segments that are down on this host",
+		[]string{"hostname"}, nil,
+	)
+
+	hostResyncingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_host_resyncing"),
+		"Number of segments in resyncing mode on this host",
+		[]string{"hostname"}, nil,
+	)
+
+	hostChangeTrackingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_host_change_tracking"),
+		"Number of segments in change tracking mode on this host",
+		[]string{"hostname"}, nil,
+	)
+
+	hostNotSyncingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_host_not_syncing"),
+		"Number of segments in not synchronized mode on this host",
+		[]string{"hostname"}, nil,
+	)
+)
+
+type segmentHostRollup struct {
+	primaryUp, primaryDown, mirrorUp, mirrorDown float64
+	resyncing, changeTracking, notSyncing        float64
+}
+
+/**
+* 函数：scrapeSegmentHostRollup
+* 功能：按主机汇总segment的角色、状态与同步模式
+ */
+func scrapeSegmentHostRollup(segments []segmentInfo, ch chan<- prometheus.Metric) {
+	hosts := make(map[string]*segmentHostRollup)
+	for _, s := range segments {
+		if s.isMaster() {
+			continue
+		}
+		h, ok := hosts[s.hostname]
+		if !ok {
+			h = &segmentHostRollup{}
+			hosts[s.hostname] = h
+		}
+		up := getStatus(s.status) == sgStatus["u"]
+		if getRole(s.role) == sgRole["p"] {
+			if up {
+				h.primaryUp++
+			} else {
+				h.primaryDown++
+			}
+		} else {
+			if up {
+				h.mirrorUp++
+			} else {
+				h.mirrorDown++
+			}
+		}
+		switch getMode(s.mode) {
+		case sgMode["r"]:
+			h.resyncing++
+		case sgMode["c"]:
+			h.changeTracking++
+		case sgMode["n"]:
+			h.notSyncing++
+		}
+	}
+
+	for hostname, h := range hosts {
+		ch <- prometheus.MustNewConstMetric(hostPrimaryUpDesc, prometheus.GaugeValue, h.primaryUp, hostname)
+		ch <- prometheus.MustNewConstMetric(hostPrimaryDownDesc, prometheus.GaugeValue, h.primaryDown, hostname)
+		ch <- prometheus.MustNewConstMetric(hostMirrorUpDesc, prometheus.GaugeValue, h.mirrorUp, hostname)
+		ch <- prometheus.MustNewConstMetric(hostMirrorDownDesc, prometheus.GaugeValue, h.mirrorDown, hostname)
+		ch <- prometheus.MustNewConstMetric(hostResyncingDesc, prometheus.GaugeValue, h.resyncing, hostname)
+		ch <- prometheus.MustNewConstMetric(hostChangeTrackingDesc, prometheus.GaugeValue, h.changeTracking, hostname)
+		ch <- prometheus.MustNewConstMetric(hostNotSyncingDesc, prometheus.GaugeValue, h.notSyncing, hostname)
+	}
+}
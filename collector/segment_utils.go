@@ -33,3 +33,50 @@ func getStatus(status string) float64 {
 	}
 	return 0
 }
+
+// 一次gp_segment_configuration查询中单个segment的信息，用于计算派生指标
+type segmentInfo struct {
+	content       string
+	role          string
+	preferredRole string
+	mode          string
+	status        string
+	hostname      string
+}
+
+// master与standby的content为-1，不参与segment派生指标的计算
+func (s segmentInfo) isMaster() bool {
+	return s.content == "-1"
+}
+
+// StateSet形式的状态名称，未识别的取值统一归为unknown而不是默认值
+const sgUnknownState = "unknown"
+
+var (
+	sgStatusStates = map[string]string{"u": "up", "d": "down"}
+	sgModeStates   = map[string]string{"s": "synchronized", "r": "resyncing", "c": "change_tracking", "n": "not_synchronized"}
+	sgRoleStates   = map[string]string{"p": "primary", "m": "mirror"}
+)
+
+/**
+* 函数：stateSetNames
+* 功能：返回StateSet中所有可能的状态名称（含unknown）
+ */
+func stateSetNames(states map[string]string) []string {
+	names := make([]string, 0, len(states)+1)
+	for _, name := range states {
+		names = append(names, name)
+	}
+	return append(names, sgUnknownState)
+}
+
+/**
+* 函数：getState
+* 功能：将gp_segment_configuration中的编码转换为状态名称
+ */
+func getState(states map[string]string, value string) string {
+	if name, ok := states[strings.ToLower(value)]; ok {
+		return name
+	}
+	return sgUnknownState
+}
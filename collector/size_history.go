@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"encoding/json"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+/**
+ *  大小历史样本
+ *  数据库大小与segment数据大小按最小间隔记录样本，在时间窗口内做线性回归得到增长速度
+ *  exporter启动（或首次记录）后需要积累至少1/4个时间窗口的样本才会上报增长速度
+ *  指定collect.history.file时样本保存到本地文件，exporter重启后继续使用；未指定时只保存在内存中
+ */
+
+// 保存样本的最小间隔；样本时间跨度至少为时间窗口的1/4且不少于3个样本时才计算增长速度，避免用几分钟内的波动外推
+const (
+	sizeHistorySampleInterval = time.Minute * 5
+	sizeHistoryMinSpanRatio   = 0.25
+	sizeHistoryMinSamples     = 3
+)
+
+var (
+	sizeHistoryFile = kingpin.Flag("collect.history.file",
+		"local file persisting size history used for growth rate and days-until-full forecasting, empty to keep history in memory only").Default("").String()
+	sizeHistoryWindow = kingpin.Flag("collect.history.window",
+		"time window of size history used for linear regression").Default("168h").Duration()
+)
+
+type sizeSample struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+type sizeHistory struct {
+	mu      sync.Mutex
+	loaded  bool
+	dirty   bool
+	samples map[string][]sizeSample
+}
+
+// 所有抓取器共享的大小历史，key为"database/<dbname>"或"segment/<dbid>"
+var sizeHistories = &sizeHistory{samples: make(map[string][]sizeSample)}
+
+/**
+* 函数：record
+* 功能：记录一个样本，返回时间窗口内样本与当前值线性回归得到的每秒增长量，样本数量或时间跨度不足时返回false
+ */
+func (h *sizeHistory) record(key string, now time.Time, value float64) (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load()
+
+	samples := h.samples[key]
+	if len(samples) == 0 || now.Sub(samples[len(samples)-1].Time) >= sizeHistorySampleInterval {
+		samples = append(samples, sizeSample{Time: now, Value: value})
+		h.samples[key] = samples
+		h.dirty = true
+	}
+	points := samples
+	if !samples[len(samples)-1].Time.Equal(now) {
+		points = append(samples[:len(samples):len(samples)], sizeSample{Time: now, Value: value})
+	}
+	minSpan := time.Duration(float64(*sizeHistoryWindow) * sizeHistoryMinSpanRatio)
+	return linearSlope(points, now.Add(-*sizeHistoryWindow), minSpan)
+}
+
+/**
+* 函数：flush
+* 功能：删除时间窗口之外的样本，有新样本时写入本地文件
+ */
+func (h *sizeHistory) flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	since := time.Now().Add(-*sizeHistoryWindow)
+	for key, samples := range h.samples {
+		i := 0
+		for i < len(samples) && samples[i].Time.Before(since) {
+			i++
+		}
+		if i == len(samples) {
+			delete(h.samples, key)
+		} else if i > 0 {
+			h.samples[key] = samples[i:]
+		}
+	}
+
+	if !h.dirty || *sizeHistoryFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(h.samples)
+	if err != nil {
+		return err
+	}
+	tmp := *sizeHistoryFile + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, *sizeHistoryFile); err != nil {
+		return err
+	}
+	h.dirty = false
+	return nil
+}
+
+func (h *sizeHistory) load() {
+	if h.loaded {
+		return
+	}
+	h.loaded = true
+	if *sizeHistoryFile == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(*sizeHistoryFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &h.samples)
+	}
+	if err != nil {
+		logger.Warnf("load size history %s failed: %v", *sizeHistoryFile, err)
+		h.samples = make(map[string][]sizeSample)
+	}
+}
+
+/**
+* 函数：linearSlope
+* 功能：对since之后的样本做最小二乘线性回归，返回每秒的变化量，样本少于sizeHistoryMinSamples个或时间跨度小于minSpan时返回false
+ */
+func linearSlope(samples []sizeSample, since time.Time, minSpan time.Duration) (float64, bool) {
+	var n, sumX, sumY, sumXY, sumXX float64
+	var origin, last time.Time
+	for _, s := range samples {
+		if s.Time.Before(since) {
+			continue
+		}
+		if n == 0 {
+			origin = s.Time
+		}
+		last = s.Time
+		x := s.Time.Sub(origin).Seconds()
+		n++
+		sumX += x
+		sumY += s.Value
+		sumXY += x * s.Value
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if n < sizeHistoryMinSamples || last.Sub(origin) < minSpan || denominator == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
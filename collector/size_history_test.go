@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLinearSlope(t *testing.T) {
+	origin := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	linear := func(count int, step time.Duration, perSecond float64) []sizeSample {
+		samples := make([]sizeSample, 0, count)
+		for i := 0; i < count; i++ {
+			samples = append(samples, sizeSample{Time: origin.Add(step * time.Duration(i)), Value: 1000 + perSecond*(step*time.Duration(i)).Seconds()})
+		}
+		return samples
+	}
+	noisy := linear(5, time.Hour, 2)
+	noisy[1].Value += 3600
+	noisy[2].Value -= 7200
+	noisy[3].Value += 3600
+
+	tests := []struct {
+		name    string
+		samples []sizeSample
+		since   time.Time
+		minSpan time.Duration
+		slope   float64
+		ok      bool
+	}{
+		{"linear growth", linear(10, time.Hour, 0.5), origin, time.Hour, 0.5, true},
+		{"shrinking", linear(10, time.Hour, -2), origin, time.Hour, -2, true},
+		{"noise around a line", noisy, origin, time.Hour, 2, true},
+		{"too few samples", linear(2, time.Hour, 1), origin, time.Hour, 0, false},
+		{"span shorter than minimum", linear(10, time.Minute*5, 1), origin, time.Hour, 0, false},
+		{"samples before since ignored", append(linear(3, time.Hour, 100)[:1], linear(10, time.Hour, 1)[3:]...), origin.Add(time.Hour), time.Hour, 1, true},
+		{"no samples", nil, origin, time.Hour, 0, false},
+	}
+	for _, tt := range tests {
+		slope, ok := linearSlope(tt.samples, tt.since, tt.minSpan)
+		if ok != tt.ok || math.Abs(slope-tt.slope) > 1e-9 {
+			t.Errorf("%s: linearSlope = %v, %v, want %v, %v", tt.name, slope, ok, tt.slope, tt.ok)
+		}
+	}
+}
+
+func TestSizeHistoryFlushAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "size_history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file, window := *sizeHistoryFile, *sizeHistoryWindow
+	defer func() { *sizeHistoryFile, *sizeHistoryWindow = file, window }()
+	*sizeHistoryFile = filepath.Join(dir, "history.json")
+	*sizeHistoryWindow = time.Hour * 4
+
+	start := time.Now().Add(-time.Hour * 5)
+	h := &sizeHistory{samples: make(map[string][]sizeSample)}
+	for i := 0; i <= 60; i++ {
+		h.record("database/sales", start.Add(time.Minute*5*time.Duration(i)), float64(i*300))
+	}
+	if err = h.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(*sizeHistoryFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	loaded := &sizeHistory{samples: make(map[string][]sizeSample)}
+	slope, ok := loaded.record("database/sales", start.Add(time.Minute*305), 61*300)
+	if !ok || math.Abs(slope-1) > 1e-9 {
+		t.Errorf("slope after load = %v, %v, want 1, true", slope, ok)
+	}
+	samples := loaded.samples["database/sales"]
+	if len(samples) != len(h.samples["database/sales"])+1 {
+		t.Errorf("loaded %d samples, want %d", len(samples)-1, len(h.samples["database/sales"]))
+	}
+	since := time.Now().Add(-*sizeHistoryWindow)
+	if samples[0].Time.Before(since.Add(-time.Minute)) {
+		t.Errorf("sample at %v outside of window was not pruned", samples[0].Time)
+	}
+}
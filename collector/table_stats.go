@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"time"
+)
+
+/**
+ *  表统计信息缺失/过期抓取器
+ *  reltuples为0或1000（未ANALYZE时的默认估计值）的大表、没有pg_statistic记录的表以及gp_toolkit.gp_stats_missing中的表
+ *  分区父表与外部表不在统计范围内
+ *  每次抓取都要在所有数据库的segment上计算全部表的大小，开销较大，需通过--collect.stats开启
+ */
+
+const (
+	statsTableFilter = `c.relkind='r' and c.relstorage<>'x'
+		and n.nspname not in ('information_schema','gp_toolkit') and n.nspname !~ '^pg_'
+		and c.oid not in (SELECT parrelid from pg_partition)`
+
+	//在segment上一次性计算所有表的大小，避免在master上对每张表调用pg_relation_size时分别下发到segment
+	tableSizeFrom = `(SELECT oid, sum(pg_relation_size(oid)) as size from gp_dist_random('pg_class')
+			where relkind='r' GROUP BY oid) z`
+
+	staleReltuplesSql = `SELECT n.nspname, count(*) from pg_class c JOIN pg_namespace n ON n.oid=c.relnamespace
+		JOIN ` + tableSizeFrom + ` ON z.oid=c.oid
+		where ` + statsTableFilter + ` and c.reltuples in (0,1000) and z.size >= $1
+		GROUP BY 1;`
+
+	missingStatisticSql = `SELECT n.nspname, count(*) from pg_class c JOIN pg_namespace n ON n.oid=c.relnamespace
+		where ` + statsTableFilter + ` and not exists (SELECT 1 from pg_statistic s where s.starelid=c.oid)
+		GROUP BY 1;`
+
+	gpStatsMissingSql = `SELECT smischema, count(*) from gp_toolkit.gp_stats_missing GROUP BY 1;`
+
+	staleTopTablesSql = `SELECT n.nspname, c.relname, z.size/(1024*1024) as size_mb
+		from pg_class c JOIN pg_namespace n ON n.oid=c.relnamespace
+		JOIN ` + tableSizeFrom + ` ON z.oid=c.oid
+		where ` + statsTableFilter + ` and c.reltuples in (0,1000) and z.size >= $1
+		order by 3 desc limit $2;`
+)
+
+var (
+	collectTableStats = kingpin.Flag("collect.stats",
+		"collect table statistics staleness of every database, computing the size of all tables on segments at each scrape").Default("false").Bool()
+	statsMinSizeMB = kingpin.Flag("collect.stats.min-size-mb",
+		"tables with reltuples 0 or 1000 larger than this size (MB) are treated as having stale statistics").Default("100").Int()
+	statsTopN = kingpin.Flag("collect.stats.top-n",
+		"number of largest tables with stale statistics listed per database, 0 to disable").Default("0").Int()
+)
+
+var (
+	staleReltuplesCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "stats_stale_reltuples_table_count"),
+		"Number of large tables whose reltuples is 0 or 1000 in each schema",
+		[]string{"dbname", "schema"},
+		nil,
+	)
+
+	missingStatisticCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "stats_missing_statistic_table_count"),
+		"Number of tables without any pg_statistic rows in each schema",
+		[]string{"dbname", "schema"},
+		nil,
+	)
+
+	gpStatsMissingCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "stats_gp_missing_table_count"),
+		"Number of tables reported by gp_toolkit.gp_stats_missing in each schema",
+		[]string{"dbname", "schema"},
+		nil,
+	)
+
+	staleTopTableSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "stats_stale_table_mb_size"),
+		"MB size of each of the top-N largest tables with stale statistics",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+)
+
+func NewTableStatsScraper() Scraper {
+	return tableStatsScraper{}
+}
+
+type tableStatsScraper struct{}
+
+func (tableStatsScraper) Name() string {
+	return "table_stats_scraper"
+}
+
+func (tableStatsScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	if !*collectTableStats {
+		return nil
+	}
+	names, err := listDatabases(db)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, dbname := range names {
+		if err := scrapeDatabaseTableStats(dbname, ch); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %v", dbname, err))
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeDatabaseTableStats(dbname string, ch chan<- prometheus.Metric) error {
+	conn, err := getDatabaseConn(dbname)
+	if err != nil {
+		return err
+	}
+	minSize := int64(*statsMinSizeMB) * 1024 * 1024
+
+	errR := scrapeSchemaCount(conn, staleReltuplesCountDesc, dbname, ch, staleReltuplesSql, minSize)
+	errS := scrapeSchemaCount(conn, missingStatisticCountDesc, dbname, ch, missingStatisticSql)
+	errG := scrapeSchemaCount(conn, gpStatsMissingCountDesc, dbname, ch, gpStatsMissingSql)
+	errT := scrapeStaleTopTables(conn, dbname, ch, minSize)
+
+	return combineErr(errR, errS, errG, errT)
+}
+
+/**
+* 函数：scrapeSchemaCount
+* 功能：执行返回(schema, count)的查询，按数据库与schema上报
+ */
+func scrapeSchemaCount(conn *sql.DB, desc *prometheus.Desc, dbname string, ch chan<- prometheus.Metric, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", query)
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var schema string
+		var count float64
+		err = rows.Scan(&schema, &count)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, count, dbname, schema)
+	}
+	return combineErr(errs...)
+}
+
+func scrapeStaleTopTables(conn *sql.DB, dbname string, ch chan<- prometheus.Metric, minSize int64) error {
+	if *statsTopN <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", staleTopTablesSql)
+	rows, err := conn.QueryContext(ctx, staleTopTablesSql, minSize, *statsTopN)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var schema, table string
+		var size float64
+		err = rows.Scan(&schema, &table, &size)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(staleTopTableSizeDesc, prometheus.GaugeValue, size, dbname, schema, table)
+	}
+	return combineErr(errs...)
+}
@@ -0,0 +1,233 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/prometheus/common/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ *  WAL(xlog)生成量与归档状态抓取器
+ */
+
+const (
+	currentLsnSql      = `SELECT pg_current_xlog_location();`
+	archiveSettingsSql = `SELECT name, setting from pg_settings where name in ('archive_mode','archive_command');`
+	//master(content=-1)与所有segment上pg_xlog目录的大小，需要超级用户权限
+	walDirSizeSql = `SELECT -1 as content, sum((pg_stat_file('pg_xlog/' || f)).size) from pg_ls_dir('pg_xlog') f
+		UNION ALL
+		SELECT content, sum(size) from (SELECT gp_execution_segment() as content,
+			(pg_stat_file('pg_xlog/' || pg_ls_dir('pg_xlog'))).size as size from gp_dist_random('gp_id')) t
+		GROUP BY content;`
+	//For GP6，GP5的pg内核没有pg_stat_archiver
+	statArchiverSql6 = `SELECT archived_count, failed_count,
+		coalesce(extract(epoch from last_archived_time), 0), coalesce(extract(epoch from last_failed_time), 0)
+		from pg_stat_archiver;`
+)
+
+// 每个xlogid对应的字节数，GP5的pg内核(8.3)中每个xlogid的最后一个段不使用，为0xFF000000，GP6(9.4)中为2^32
+const (
+	xlogIdBytes5 = 0xFF000000
+	xlogIdBytes6 = 1 << 32
+)
+
+var (
+	walCurrentLsnDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_current_lsn_bytes_total"),
+		"Current WAL write location of master in bytes, use rate() for WAL bytes generated",
+		nil, nil,
+	)
+
+	walArchiveModeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_archive_mode"),
+		"Whether archive_mode is enabled",
+		nil, nil,
+	)
+
+	walArchiveCommandDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_archive_command_configured"),
+		"Whether archive_command is configured",
+		nil, nil,
+	)
+
+	walDirSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_directory_bytes"),
+		"Size of pg_xlog directory in bytes on master (content -1) and each segment",
+		[]string{"content"}, nil,
+	)
+
+	walArchivedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_archived_total"),
+		"Number of WAL files that have been successfully archived",
+		nil, nil,
+	)
+
+	walArchiveFailedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_archive_failed_total"),
+		"Number of failed attempts for archiving WAL files",
+		nil, nil,
+	)
+
+	walLastArchivedTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_last_archived_time_seconds"),
+		"Timestamp of the last successful archive operation, 0 if never archived",
+		nil, nil,
+	)
+
+	walLastFailedTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_last_archive_failed_time_seconds"),
+		"Timestamp of the last failed archive operation, 0 if never failed",
+		nil, nil,
+	)
+)
+
+func NewWalScraper6() Scraper {
+	return walScraper6{}
+}
+
+func NewWalScraper5() Scraper {
+	return walScraper5{}
+}
+
+type walScraper6 struct{}
+
+type walScraper5 struct{}
+
+func (walScraper6) Name() string {
+	return "wal_scraper"
+}
+
+func (walScraper5) Name() string {
+	return "wal_scraper"
+}
+
+func (walScraper6) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	errL := scrapeCurrentLsn(db, xlogIdBytes6, ch)
+	errS := scrapeArchiveSettings(db, ch)
+	errD := scrapeWalDirSize(db, ch)
+	errA := scrapeStatArchiver6(db, ch)
+	return combineErr(errL, errS, errD, errA)
+}
+
+func (walScraper5) Scrape(db *sql.DB, ch chan<- prometheus.Metric) error {
+	errL := scrapeCurrentLsn(db, xlogIdBytes5, ch)
+	errS := scrapeArchiveSettings(db, ch)
+	errD := scrapeWalDirSize(db, ch)
+	return combineErr(errL, errS, errD)
+}
+
+func scrapeCurrentLsn(db *sql.DB, xlogIdBytes uint64, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", currentLsnSql)
+	var location string
+	if err := db.QueryRowContext(ctx, currentLsnSql).Scan(&location); err != nil {
+		return err
+	}
+	lsn, err := parseLsn(location, xlogIdBytes)
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(walCurrentLsnDesc, prometheus.CounterValue, lsn)
+	return nil
+}
+
+func scrapeArchiveSettings(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", archiveSettingsSql)
+	rows, err := db.QueryContext(ctx, archiveSettingsSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var name, setting string
+		err = rows.Scan(&name, &setting)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		switch name {
+		case "archive_mode":
+			var mode float64
+			if setting == "on" || setting == "always" {
+				mode = 1
+			}
+			ch <- prometheus.MustNewConstMetric(walArchiveModeDesc, prometheus.GaugeValue, mode)
+		case "archive_command":
+			var configured float64
+			if setting != "" && setting != "(disabled)" {
+				configured = 1
+			}
+			ch <- prometheus.MustNewConstMetric(walArchiveCommandDesc, prometheus.GaugeValue, configured)
+		}
+	}
+	return combineErr(errs...)
+}
+
+func scrapeWalDirSize(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", walDirSizeSql)
+	rows, err := db.QueryContext(ctx, walDirSizeSql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	errs := make([]error, 0)
+	for rows.Next() {
+		var content string
+		var size float64
+		err = rows.Scan(&content, &size)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(walDirSizeDesc, prometheus.GaugeValue, size, content)
+	}
+	return combineErr(errs...)
+}
+
+func scrapeStatArchiver6(db *sql.DB, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	logger.Infof("Query Database: %s", statArchiverSql6)
+	var archived, failed, lastArchived, lastFailed float64
+	err := db.QueryRowContext(ctx, statArchiverSql6).Scan(&archived, &failed, &lastArchived, &lastFailed)
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(walArchivedDesc, prometheus.CounterValue, archived)
+	ch <- prometheus.MustNewConstMetric(walArchiveFailedDesc, prometheus.CounterValue, failed)
+	ch <- prometheus.MustNewConstMetric(walLastArchivedTimeDesc, prometheus.GaugeValue, lastArchived)
+	ch <- prometheus.MustNewConstMetric(walLastFailedTimeDesc, prometheus.GaugeValue, lastFailed)
+	return nil
+}
+
+/**
+* 函数：parseLsn
+* 功能：将xlog位置（如 1/A2B3C4D0）按每个xlogid的字节数转换为字节偏移量
+ */
+func parseLsn(location string, xlogIdBytes uint64) (float64, error) {
+	parts := strings.Split(location, "/")
+	if len(parts) != 2 {
+		return 0, errors.New(fmt.Sprintf("invalid xlog location: %s", location))
+	}
+	high, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	low, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	return float64(high*xlogIdBytes + low), nil
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	logger "github.com/prometheus/common/log"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"greenplum-exporter/collector"
+	"html"
 	"net/http"
+	"time"
 )
 
 var (
@@ -15,6 +18,7 @@ var (
 	disableDefaultMetrics = kingpin.Flag("disableDefaultMetrics", "do not report default metrics(go metrics and process metrics)").Default("true").Bool()
 	greenplumVersion      = kingpin.Flag("greenplumVersion", "greenplum Server Version, options: gposs5-open " +
 		"source greenplum 5.x, gposs6-open source greenplum 6.x, gpdb5-pivotal greenplum 5.x, gpdb6-pivotal greenplum 6.x").Default("gposs6").String()
+	readyFreshness        = kingpin.Flag("web.ready-freshness", "/readyz succeeds only if the last successful connection check is within this duration").Default("5m").Duration()
 )
 
 var gposs6Scrapers = map[collector.Scraper]bool{
@@ -27,6 +31,23 @@ var gposs6Scrapers = map[collector.Scraper]bool{
 	collector.NewConnectionsScraper6():  true,
 	collector.NewClusterStateScraper6(): true,
 	collector.NewBgWriterStateScraper6():true,
+	collector.NewPartitionScraper():     true,
+	collector.NewSchemaSizeScraper():    true,
+	collector.NewDatabaseStatScraper6(): true,
+	collector.NewIndexUsageScraper():    true,
+	collector.NewTableStatsScraper():    true,
+	collector.NewFtsScraper():           true,
+	collector.NewDistributedXactsScraper(): true,
+	collector.NewWalScraper6():          true,
+	collector.NewLogSystemScraper():     true,
+	collector.NewExternalTableScraper6(): true,
+	collector.NewGucScraper():           true,
+	collector.NewRolesScraper():         true,
+	collector.NewOrphanScraper():        true,
+	collector.NewLockCancelScraper6():   true,
+	collector.NewCatalogScraper():       true,
+	collector.NewBackupScraper():        true,
+	collector.NewSegmentDiskScraper():   true,
 }
 
 var gposs5Scrapers = map[collector.Scraper]bool{
@@ -39,6 +60,23 @@ var gposs5Scrapers = map[collector.Scraper]bool{
 	collector.NewConnectionsScraper5():  true,
 	collector.NewClusterStateScraper5(): true,
 	collector.NewBgWriterStateScraper5():true,
+	collector.NewPartitionScraper():     true,
+	collector.NewSchemaSizeScraper():    true,
+	collector.NewDatabaseStatScraper5(): true,
+	collector.NewIndexUsageScraper():    true,
+	collector.NewTableStatsScraper():    true,
+	collector.NewFtsScraper():           true,
+	collector.NewDistributedXactsScraper(): true,
+	collector.NewWalScraper5():          true,
+	collector.NewLogSystemScraper():     true,
+	collector.NewExternalTableScraper5(): true,
+	collector.NewGucScraper():           true,
+	collector.NewRolesScraper():         true,
+	collector.NewOrphanScraper():        true,
+	collector.NewLockCancelScraper5():   true,
+	collector.NewCatalogScraper():       true,
+	collector.NewBackupScraper():        true,
+	collector.NewSegmentDiskScraper():   true,
 }
 
 var gpdb6Scrapers = map[collector.Scraper]bool{
@@ -55,6 +93,24 @@ var gpdb6Scrapers = map[collector.Scraper]bool{
 	collector.NewConnectionsScraper6():  true,
 	collector.NewClusterStateScraper6(): true,
 	collector.NewBgWriterStateScraper6():true,
+	collector.NewPartitionScraper():     true,
+	collector.NewSchemaSizeScraper():    true,
+	collector.NewDatabaseStatScraper6(): true,
+	collector.NewIndexUsageScraper():    true,
+	collector.NewTableStatsScraper():    true,
+	collector.NewFtsScraper():           true,
+	collector.NewDistributedXactsScraper(): true,
+	collector.NewWalScraper6():          true,
+	collector.NewQueriesHistoryScraper(): true,
+	collector.NewLogAlertHistoryScraper(): true,
+	collector.NewExternalTableScraper6(): true,
+	collector.NewGucScraper():           true,
+	collector.NewRolesScraper():         true,
+	collector.NewOrphanScraper():        true,
+	collector.NewLockCancelScraper6():   true,
+	collector.NewCatalogScraper():       true,
+	collector.NewBackupScraper():        true,
+	collector.NewSegmentDiskScraper():   true,
 }
 
 var gpdb5Scrapers = map[collector.Scraper]bool{
@@ -71,6 +127,24 @@ var gpdb5Scrapers = map[collector.Scraper]bool{
 	collector.NewConnectionsScraper5():  true,
 	collector.NewClusterStateScraper5(): true,
 	collector.NewBgWriterStateScraper5():true,
+	collector.NewPartitionScraper():     true,
+	collector.NewSchemaSizeScraper():    true,
+	collector.NewDatabaseStatScraper5(): true,
+	collector.NewIndexUsageScraper():    true,
+	collector.NewTableStatsScraper():    true,
+	collector.NewFtsScraper():           true,
+	collector.NewDistributedXactsScraper(): true,
+	collector.NewWalScraper5():          true,
+	collector.NewQueriesHistoryScraper(): true,
+	collector.NewLogAlertHistoryScraper(): true,
+	collector.NewExternalTableScraper5(): true,
+	collector.NewGucScraper():           true,
+	collector.NewRolesScraper():         true,
+	collector.NewOrphanScraper():        true,
+	collector.NewLockCancelScraper5():   true,
+	collector.NewCatalogScraper():       true,
+	collector.NewBackupScraper():        true,
+	collector.NewSegmentDiskScraper():   true,
 }
 
 var gathers prometheus.Gatherers
@@ -81,36 +155,46 @@ func main() {
 	logger.AddFlags(kingpin.CommandLine)
 	kingpin.Parse()
 
-	var metricsHandleFunc http.HandlerFunc
+	var scrapers map[collector.Scraper]bool
 	if *greenplumVersion == "gposs6" {
-		metricsHandleFunc = newHandler(*disableDefaultMetrics, gposs6Scrapers)
+		scrapers = gposs6Scrapers
 	} else if *greenplumVersion == "gposs5" {
-		metricsHandleFunc = newHandler(*disableDefaultMetrics, gposs5Scrapers)
+		scrapers = gposs5Scrapers
 	} else if *greenplumVersion == "gpdb6" {
-		metricsHandleFunc = newHandler(*disableDefaultMetrics, gpdb6Scrapers)
+		scrapers = gpdb6Scrapers
 	} else if *greenplumVersion == "gpdb5" {
-		metricsHandleFunc = newHandler(*disableDefaultMetrics, gpdb5Scrapers)
+		scrapers = gpdb5Scrapers
 	} else {
-		metricsHandleFunc = newHandler(*disableDefaultMetrics, gposs6Scrapers)
+		scrapers = gposs6Scrapers
 	}
+	greenplumCollector := collector.NewCollector(enabledScrapers(scrapers))
+	// 连接检查间隔取新鲜度阈值的一半，保证/readyz在阈值内至少有一次检查结果
+	greenplumCollector.StartConnectionCheck(*readyFreshness / 2)
 	mux := http.NewServeMux()
-	mux.HandleFunc(*metricPath, metricsHandleFunc)
+	mux.HandleFunc(*metricPath, newHandler(*disableDefaultMetrics, greenplumCollector))
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", newReadyzHandler(greenplumCollector))
+	if *metricPath != "/" {
+		mux.HandleFunc("/", newLandingHandler(greenplumCollector))
+	}
 
 	logger.Warnf("Greenplum exporter started and will listening on : %s", *listenAddress)
 	logger.Error(http.ListenAndServe(*listenAddress, mux).Error())
 }
 
-func newHandler(disableDefaultMetrics bool, scrapers map[collector.Scraper]bool) http.HandlerFunc {
-	registry := prometheus.NewRegistry()
-	enabledScrapers := make([]collector.Scraper, 0, 16)
+func enabledScrapers(scrapers map[collector.Scraper]bool) []collector.Scraper {
+	enabled := make([]collector.Scraper, 0, 16)
 
 	for scraper, enable := range scrapers {
 		if enable {
-			enabledScrapers = append(enabledScrapers, scraper)
+			enabled = append(enabled, scraper)
 		}
 	}
+	return enabled
+}
 
-	greenplumCollector := collector.NewCollector(enabledScrapers)
+func newHandler(disableDefaultMetrics bool, greenplumCollector *collector.GreenPlumCollector) http.HandlerFunc {
+	registry := prometheus.NewRegistry()
 	registry.MustRegister(greenplumCollector)
 
 	if disableDefaultMetrics {
@@ -125,3 +209,64 @@ func newHandler(disableDefaultMetrics bool, scrapers map[collector.Scraper]bool)
 
 	return handler.ServeHTTP
 }
+
+/**
+* 函数：healthzHandler
+* 功能：存活检查，进程存活即返回200，不访问数据库
+ */
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+/**
+* 函数：newReadyzHandler
+* 功能：就绪检查，最近一次抓取时的连接检查在web.ready-freshness内成功则返回200，否则返回503，不访问数据库
+ */
+func newReadyzHandler(greenplumCollector *collector.GreenPlumCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		last := greenplumCollector.LastConnectionCheck()
+		if last.IsZero() {
+			http.Error(w, "no successful connection check yet", http.StatusServiceUnavailable)
+			return
+		}
+		if age := time.Since(last); age > *readyFreshness {
+			http.Error(w, fmt.Sprintf("last successful connection check %s ago", age.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "ok, last successful connection check at %s\n", last.Format(time.RFC3339))
+	}
+}
+
+/**
+* 函数：newLandingHandler
+* 功能：首页，列出所有端点以及配置与识别出的集群版本
+ */
+func newLandingHandler(greenplumCollector *collector.GreenPlumCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		flavor, version := greenplumCollector.Flavor()
+		if flavor == "" {
+			flavor = "not connected yet"
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprintf(w, `<html>
+<head><title>Greenplum Exporter</title></head>
+<body>
+<h1>Greenplum Exporter</h1>
+<p>Configured version: %s</p>
+<p>Detected version: %s</p>
+<p>%s</p>
+<ul>
+<li><a href="%s">%s</a> - metrics</li>
+<li><a href="/healthz">/healthz</a> - liveness</li>
+<li><a href="/readyz">/readyz</a> - readiness</li>
+</ul>
+</body>
+</html>
+`, html.EscapeString(*greenplumVersion), html.EscapeString(flavor), html.EscapeString(version),
+			html.EscapeString(*metricPath), html.EscapeString(*metricPath))
+	}
+}